@@ -2,13 +2,20 @@ package interpreter
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Program represents the root node of the abstract syntax tree (AST).
 // It contains a block of statements that make up the program.
 type Program struct {
 	Statements Block
+	resolved   bool                       // Whether variables have been bound to slots
+	sourcesMu  sync.Mutex                 // Guards sources, which concurrent executions add to
+	sources    map[string][]byte          // Source code of the files imported while it ran, by filename
+	code       atomic.Pointer[codeObject] // Bytecode compiled the first time it ran on the VM
 }
 
 // String returns a string representation of the program.
@@ -48,18 +55,10 @@ func (s *Assign) Position() Position { return s.pos }
 
 // String returns a string representation of the assignment.
 func (s *Assign) String() string {
+	// An Assign built without an operator is a plain assignment
 	opStr := "="
-	switch s.Operator {
-	case PLUSEQUAL:
-		opStr = "+="
-	case MINUSEQUAL:
-		opStr = "-="
-	case TIMESEQUAL:
-		opStr = "*="
-	case DIVIDEEQUAL:
-		opStr = "/="
-	case MODULOEQUAL:
-		opStr = "%="
+	if s.Operator != ILLEGAL {
+		opStr = s.Operator.String()
 	}
 	return fmt.Sprintf("%s %s %s", s.Target, opStr, s.Value)
 }
@@ -122,35 +121,195 @@ func (s *While) String() string {
 	return fmt.Sprintf("while %s {\n%s\n}", s.Condition, indent(s.Body.String()))
 }
 
-// For represents a for-in loop statement.
+// For represents a for-in loop statement. With a key variable, the loop
+// iterates over the keys or indexes of the collection and their values.
 type For struct {
-	pos      Position   // Source position
-	Name     string     // Loop variable name
-	Iterable Expression // The collection to iterate over
-	Body     Block      // The block to execute for each item
+	pos        Position   // Source position
+	Key        string     // Key or index variable name (optional)
+	Name       string     // Loop variable name
+	Target     Expression // Destructuring target for each item, instead of Name (optional)
+	Iterable   Expression // The collection to iterate over
+	Body       Block      // The block to execute for each item
+	keyBinding binding    // Resolved location of the key variable
+	binding    binding    // Resolved location of the loop variable
 }
 
 func (s *For) Position() Position { return s.pos }
 
 // String returns a string representation of the for statement.
 func (s *For) String() string {
-	return fmt.Sprintf("for %s in %s {\n%s\n}", s.Name, s.Iterable, indent(s.Body.String()))
+	return fmt.Sprintf("%s {\n%s\n}", s.header(), indent(s.Body.String()))
+}
+
+// header returns a string representation of the for statement without its
+// body.
+func (s *For) header() string {
+	name := s.Name
+	if s.Target != nil {
+		name = s.Target.String()
+	}
+	if s.Key != "" {
+		name = s.Key + ", " + name
+	}
+	return fmt.Sprintf("for %s in %s", name, s.Iterable)
 }
 
 // TryCatch represents a try-catch statement for error handling.
 type TryCatch struct {
-	pos        Position // Source position
-	TryBlock   Block    // The block to try executing
-	ErrVar     string   // The variable name to hold the caught error
-	CatchBlock Block    // The block to execute if an error occurs
+	pos          Position // Source position
+	TryBlock     Block    // The block to try executing
+	ErrVar       string   // The variable name to hold the caught error, empty without a catch block
+	CatchBlock   Block    // The block to execute if an error occurs (optional if there is a finally block)
+	FinallyBlock Block    // The block run however try and catch are left (optional)
+	errBinding   binding  // Resolved location of the error variable
 }
 
 func (s *TryCatch) Position() Position { return s.pos }
 
 // String returns a string representation of the try-catch statement.
 func (s *TryCatch) String() string {
-	return fmt.Sprintf("try:\n%s\nend catch %s:\n%s\nend",
-		indent(s.TryBlock.String()), s.ErrVar, indent(s.CatchBlock.String()))
+	str := fmt.Sprintf("try:\n%s\nend", indent(s.TryBlock.String()))
+	if s.ErrVar != "" {
+		str += fmt.Sprintf(" catch %s:\n%s\nend", s.ErrVar, indent(s.CatchBlock.String()))
+	}
+	if s.FinallyBlock != nil {
+		str += fmt.Sprintf(" finally:\n%s\nend", indent(s.FinallyBlock.String()))
+	}
+	return str
+}
+
+// Match represents a match statement, which runs the first case arm whose
+// pattern matches the subject value.
+type Match struct {
+	pos     Position    // Source position
+	Subject Expression  // The value to match
+	Cases   []MatchCase // The case arms, in order
+}
+
+func (s *Match) Position() Position { return s.pos }
+
+// String returns a string representation of the match statement.
+func (s *Match) String() string {
+	arms := []string{}
+	for _, arm := range s.Cases {
+		arms = append(arms, arm.String())
+	}
+	return fmt.Sprintf("match %s {\n%s\n}", s.Subject, indent(strings.Join(arms, "\n")))
+}
+
+// MatchCase represents a case arm of a match statement.
+type MatchCase struct {
+	Pattern Pattern    // The pattern to match, or nil for the default arm
+	Guard   Expression // Condition that must also hold (optional)
+	Body    Block      // The block to execute if the arm matches
+	scope   *scope     // Variables captured by the pattern, or nil if none
+}
+
+// String returns a string representation of the case arm.
+func (c MatchCase) String() string {
+	head := "default"
+	if c.Pattern != nil {
+		head = "case " + c.Pattern.String()
+	}
+	if c.Guard != nil {
+		head += fmt.Sprintf(" if %s", c.Guard)
+	}
+	return fmt.Sprintf("%s {\n%s\n}", head, indent(c.Body.String()))
+}
+
+// Pattern is an interface that all patterns of match case arms implement.
+type Pattern interface {
+	// Position returns the source code position of the pattern.
+	Position() Position
+	// String returns a string representation of the pattern.
+	String() string
+}
+
+// LiteralPattern matches values equal to a literal.
+type LiteralPattern struct {
+	pos   Position // Source position
+	Value any      // The literal value
+}
+
+func (p *LiteralPattern) Position() Position { return p.pos }
+
+// String returns a string representation of the literal pattern.
+func (p *LiteralPattern) String() string {
+	return (&Literal{p.pos, p.Value}).String()
+}
+
+// CapturePattern matches any value and binds it to a variable. The name _
+// matches without binding anything.
+type CapturePattern struct {
+	pos  Position // Source position
+	Name string   // Variable name
+	slot int      // Slot of the variable in the arm scope
+}
+
+func (p *CapturePattern) Position() Position { return p.pos }
+
+// String returns a string representation of the capture pattern.
+func (p *CapturePattern) String() string {
+	return p.Name
+}
+
+// TypePattern matches values of a type, as named by typeof(), and
+// optionally binds them to a variable.
+type TypePattern struct {
+	pos     Position        // Source position
+	Type    string          // Type name
+	Capture *CapturePattern // Variable to bind (optional)
+}
+
+func (p *TypePattern) Position() Position { return p.pos }
+
+// String returns a string representation of the type pattern.
+func (p *TypePattern) String() string {
+	if p.Capture != nil {
+		return fmt.Sprintf("%s %s", p.Type, p.Capture)
+	}
+	return p.Type
+}
+
+// ArrayPattern matches arrays element by element. With a rest pattern, the
+// array may be longer and the remaining elements are matched as an array.
+type ArrayPattern struct {
+	pos      Position        // Source position
+	Elements []Pattern       // Patterns of the leading elements
+	Rest     *CapturePattern // Pattern for the remaining elements (optional)
+}
+
+func (p *ArrayPattern) Position() Position { return p.pos }
+
+// String returns a string representation of the array pattern.
+func (p *ArrayPattern) String() string {
+	elements := []string{}
+	for _, element := range p.Elements {
+		elements = append(elements, element.String())
+	}
+	if p.Rest != nil {
+		elements = append(elements, "..."+p.Rest.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+}
+
+// ObjectPattern matches objects having all the listed keys, with values
+// matching their patterns. Other keys are ignored.
+type ObjectPattern struct {
+	pos    Position  // Source position
+	Keys   []string  // Keys the object must have
+	Values []Pattern // Patterns of the values of the keys
+}
+
+func (p *ObjectPattern) Position() Position { return p.pos }
+
+// String returns a string representation of the object pattern.
+func (p *ObjectPattern) String() string {
+	items := []string{}
+	for i, key := range p.Keys {
+		items = append(items, fmt.Sprintf("%q: %s", key, p.Values[i]))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(items, ", "))
 }
 
 // Return represents a return statement.
@@ -166,6 +325,92 @@ func (s *Return) String() string {
 	return fmt.Sprintf("return %s", s.Result)
 }
 
+// Throw represents a throw statement that raises a value as an error.
+type Throw struct {
+	pos   Position   // Source position
+	Value Expression // The value to throw
+}
+
+func (s *Throw) Position() Position { return s.pos }
+
+// String returns a string representation of the throw statement.
+func (s *Throw) String() string {
+	return fmt.Sprintf("throw %s", s.Value)
+}
+
+// Yield represents a yield statement, which makes the enclosing function a
+// generator function.
+type Yield struct {
+	pos   Position   // Source position
+	Value Expression // The value to produce
+}
+
+func (s *Yield) Position() Position { return s.pos }
+
+// String returns a string representation of the yield statement.
+func (s *Yield) String() string {
+	return fmt.Sprintf("yield %s", s.Value)
+}
+
+// NameDeclaration represents a global or nonlocal statement. In the whole
+// body of the function it appears in, the names refer to global variables or
+// to variables of an enclosing function, instead of being local when they
+// are assigned.
+type NameDeclaration struct {
+	pos    Position // Source position
+	Global bool     // Whether the statement is global rather than nonlocal
+	Names  []string // The declared names
+}
+
+func (s *NameDeclaration) Position() Position { return s.pos }
+
+// String returns a string representation of the declaration.
+func (s *NameDeclaration) String() string {
+	return fmt.Sprintf("%s %s", s.keyword(), strings.Join(s.Names, ", "))
+}
+
+// keyword returns the keyword the declaration starts with.
+func (s *NameDeclaration) keyword() string {
+	if s.Global {
+		return "global"
+	}
+	return "nonlocal"
+}
+
+// DefaultValue assigns the default value of a parameter before a function
+// body runs, if the call gave no argument for the parameter. The parser adds
+// one to the prologue of a function for each parameter with a default.
+type DefaultValue struct {
+	pos       Position   // Source position
+	Parameter *Variable  // The parameter to assign
+	Value     Expression // The default value, evaluated on each call
+}
+
+func (s *DefaultValue) Position() Position { return s.pos }
+
+// String returns a string representation of the default value assignment.
+func (s *DefaultValue) String() string {
+	return fmt.Sprintf("default %s = %s", s.Parameter, s.Value)
+}
+
+// Element is the innermost statement of a comprehension, which adds a value
+// to the array being built, or a key and value to the object being built.
+type Element struct {
+	pos   Position   // Source position
+	Key   Expression // The key, or nil in array comprehensions
+	Value Expression // The value
+}
+
+func (s *Element) Position() Position { return s.pos }
+
+// String returns a string representation of the comprehension element.
+func (s *Element) String() string {
+	if s.Key == nil {
+		return s.Value.String()
+	}
+	return fmt.Sprintf("%s: %s", s.Key, s.Value)
+}
+
 // ExpressionStatement represents a statement that consists of just an expression.
 type ExpressionStatement struct {
 	pos        Position   // Source position
@@ -181,11 +426,15 @@ func (s *ExpressionStatement) String() string {
 
 // FunctionDefinition represents a function declaration statement.
 type FunctionDefinition struct {
-	pos        Position // Source position
-	Name       string   // Function name
-	Parameters []string // Parameter names
-	Ellipsis   bool     // Whether the function accepts variable arguments
-	Body       Block    // Function body
+	pos        Position     // Source position
+	Name       string       // Function name
+	Parameters []string     // Parameter names
+	Defaults   []Expression // Default values of the parameters, nil if none has one
+	Ellipsis   bool         // Whether the function accepts variable arguments
+	Body       Block        // Function body
+	prologue   Block        // Assigns default values and destructures parameters before the body
+	binding    binding      // Resolved location of the function name
+	scope      *scope       // Local variables of the function
 }
 
 func (s *FunctionDefinition) Position() Position { return s.pos }
@@ -201,7 +450,20 @@ func (s *FunctionDefinition) String() string {
 		bodyStr = "\n" + indent(s.Body.String()) + "\n"
 	}
 	return fmt.Sprintf("fun %s(%s%s) {%s}",
-		s.Name, strings.Join(s.Parameters, ", "), ellipsisStr, bodyStr)
+		s.Name, paramsString(s.Parameters, s.Defaults), ellipsisStr, bodyStr)
+}
+
+// paramsString returns the parameters of a function separated by commas,
+// with their default values.
+func paramsString(params []string, defaults []Expression) string {
+	strs := []string{}
+	for i, param := range params {
+		if defaults != nil && defaults[i] != nil {
+			param = fmt.Sprintf("%s = %s", param, defaults[i])
+		}
+		strs = append(strs, param)
+	}
+	return strings.Join(strs, ", ")
 }
 
 // Expression is an interface that all expression nodes in the AST must implement.
@@ -251,7 +513,9 @@ type Call struct {
 	pos       Position     // Source position
 	Function  Expression   // The function to call
 	Arguments []Expression // Function arguments
-	Ellipsis  bool         // Whether to unpack the last argument
+	Names     []string     // Names of the trailing named arguments
+	Ellipsis  bool         // Whether to unpack the last positional argument
+	Optional  bool         // Whether the call is skipped if Function is null (f?.())
 }
 
 func (e *Call) Position() Position { return e.pos }
@@ -259,14 +523,22 @@ func (e *Call) Position() Position { return e.pos }
 // String returns a string representation of the function call.
 func (e *Call) String() string {
 	args := []string{}
-	for _, arg := range e.Arguments {
-		args = append(args, arg.String())
+	positional := len(e.Arguments) - len(e.Names)
+	for i, arg := range e.Arguments {
+		switch {
+		case i >= positional:
+			args = append(args, fmt.Sprintf("%s: %s", e.Names[i-positional], arg))
+		case i == positional-1 && e.Ellipsis:
+			args = append(args, arg.String()+"...")
+		default:
+			args = append(args, arg.String())
+		}
 	}
-	ellipsisStr := ""
-	if e.Ellipsis {
-		ellipsisStr = "..."
+	optional := ""
+	if e.Optional {
+		optional = "?."
 	}
-	return fmt.Sprintf("%s(%s%s)", e.Function, strings.Join(args, ", "), ellipsisStr)
+	return fmt.Sprintf("%s%s(%s)", e.Function, optional, strings.Join(args, ", "))
 }
 
 // Literal represents a literal value (number, string, boolean, nil).
@@ -288,6 +560,31 @@ func (e *Literal) String() string {
 	return fmt.Sprintf("%v", e.Value)
 }
 
+// Interpolation represents a string literal with ${...} interpolations,
+// whose values are converted to strings as str() does.
+type Interpolation struct {
+	pos     Position     // Source position
+	Strings []string     // The text around the interpolations, one more than Values
+	Values  []Expression // The interpolated expressions
+}
+
+func (e *Interpolation) Position() Position { return e.pos }
+
+// String returns a string representation of the interpolated string.
+func (e *Interpolation) String() string {
+	var sb strings.Builder
+	sb.WriteString(`"`)
+	for i, str := range e.Strings {
+		if i > 0 {
+			fmt.Fprintf(&sb, "${%s}", e.Values[i-1])
+		}
+		quoted := strconv.Quote(str)
+		sb.WriteString(strings.ReplaceAll(quoted[1:len(quoted)-1], "${", `\${`))
+	}
+	sb.WriteString(`"`)
+	return sb.String()
+}
+
 // List represents a list literal [value1, value2, ...].
 type List struct {
 	pos    Position     // Source position
@@ -305,7 +602,23 @@ func (e *List) String() string {
 	return fmt.Sprintf("[%s]", strings.Join(values, ", "))
 }
 
-// MapItem represents a key-value pair in a map literal.
+// Spread represents an element of a list literal or map literal that spreads
+// the values of an iterable into the list, or the keys and values of an
+// object into the map (...value).
+type Spread struct {
+	pos   Position   // Source position of the ...
+	Value Expression // The value to spread
+}
+
+func (e *Spread) Position() Position { return e.pos }
+
+// String returns a string representation of the spread element.
+func (e *Spread) String() string {
+	return fmt.Sprintf("...%s", e.Value)
+}
+
+// MapItem represents a key-value pair in a map literal, or a spread object
+// if Key is nil and Value is a *Spread.
 type MapItem struct {
 	Key   Expression // Map key
 	Value Expression // Map value
@@ -323,17 +636,81 @@ func (e *Map) Position() Position { return e.pos }
 func (e *Map) String() string {
 	items := []string{}
 	for _, item := range e.Items {
+		if item.Key == nil {
+			items = append(items, item.Value.String())
+			continue
+		}
 		items = append(items, fmt.Sprintf("%s: %s", item.Key, item.Value))
 	}
 	return fmt.Sprintf("{%s}", strings.Join(items, ", "))
 }
 
+// Comprehension represents an array comprehension [value for x in xs if c]
+// or an object comprehension {key: value for (k, v in obj)}. Its for and if
+// clauses are nested For and If statements with an Element statement
+// innermost, run in a scope of their own so the loop variables don't leak.
+type Comprehension struct {
+	pos     Position // Source position
+	Element *Element // The element added by each iteration
+	Loop    *For     // The first for clause, with the other clauses in its body
+	scope   *scope   // Resolved local variables of the comprehension
+}
+
+func (e *Comprehension) Position() Position { return e.pos }
+
+// String returns a string representation of the comprehension.
+func (e *Comprehension) String() string {
+	clauses := []string{}
+	for clause := Statement(e.Loop); clause != e.Element; {
+		switch c := clause.(type) {
+		case *For:
+			clauses = append(clauses, c.header())
+			clause = c.Body[0]
+		case *If:
+			clauses = append(clauses, "if "+c.Condition.String())
+			clause = c.Body[0]
+		}
+	}
+	if e.Element.Key == nil {
+		return fmt.Sprintf("[%s %s]", e.Element, strings.Join(clauses, " "))
+	}
+	return fmt.Sprintf("{%s %s}", e.Element, strings.Join(clauses, " "))
+}
+
+// Slice represents a slice subscript (container[start:end:step]), whose
+// bounds may each be omitted.
+type Slice struct {
+	pos   Position   // Source position
+	Start Expression // First index, or nil
+	End   Expression // Index to stop before, or nil
+	Step  Expression // Distance between indexes, or nil
+}
+
+func (e *Slice) Position() Position { return e.pos }
+
+// String returns a string representation of the slice.
+func (e *Slice) String() string {
+	bound := func(expr Expression) string {
+		if expr == nil {
+			return ""
+		}
+		return expr.String()
+	}
+	if e.Step == nil {
+		return fmt.Sprintf("%s:%s", bound(e.Start), bound(e.End))
+	}
+	return fmt.Sprintf("%s:%s:%s", bound(e.Start), bound(e.End), bound(e.Step))
+}
+
 // FunctionExpression represents an anonymous function expression.
 type FunctionExpression struct {
-	pos        Position // Source position
-	Parameters []string // Parameter names
-	Ellipsis   bool     // Whether the function accepts variable arguments
-	Body       Block    // Function body
+	pos        Position     // Source position
+	Parameters []string     // Parameter names
+	Defaults   []Expression // Default values of the parameters, nil if none has one
+	Ellipsis   bool         // Whether the function accepts variable arguments
+	Body       Block        // Function body
+	prologue   Block        // Assigns default values and destructures parameters before the body
+	scope      *scope       // Local variables of the function
 }
 
 func (e *FunctionExpression) Position() Position { return e.pos }
@@ -348,7 +725,7 @@ func (e *FunctionExpression) String() string {
 	if len(e.Body) != 0 {
 		bodyStr = "\n" + indent(e.Body.String()) + "\n"
 	}
-	return fmt.Sprintf("fun(%s%s) {%s}", strings.Join(e.Parameters, ", "), ellipsisStr, bodyStr)
+	return fmt.Sprintf("fun(%s%s) {%s}", paramsString(e.Parameters, e.Defaults), ellipsisStr, bodyStr)
 }
 
 // Subscript represents a container subscript expression (container[index]).
@@ -356,19 +733,85 @@ type Subscript struct {
 	pos       Position   // Source position
 	Container Expression // The container to index into (list, map, etc.)
 	Subscript Expression // The index expression
+	Optional  bool       // Whether the subscript is skipped if Container is null (a?.[i])
 }
 
 func (e *Subscript) Position() Position { return e.pos }
 
 // String returns a string representation of the subscript expression.
 func (e *Subscript) String() string {
-	return fmt.Sprintf("%s[%s]", e.Container, e.Subscript)
+	optional := ""
+	if e.Optional {
+		optional = "?."
+	}
+	return fmt.Sprintf("%s%s[%s]", e.Container, optional, e.Subscript)
+}
+
+// OptionalChain represents a chain of calls and subscripts containing
+// optional links (a?.b, a?.[i], f?.()). When an optional link finds null,
+// the rest of the chain is skipped and the whole chain evaluates to null.
+type OptionalChain struct {
+	pos        Position   // Source position
+	Expression Expression // The outermost call or subscript of the chain
+}
+
+func (e *OptionalChain) Position() Position { return e.pos }
+
+// String returns a string representation of the optional chain.
+func (e *OptionalChain) String() string {
+	return e.Expression.String()
+}
+
+// ArrayTarget represents an array destructuring target [a, b, ...rest],
+// which assigns the elements of an array to the targets it lists.
+type ArrayTarget struct {
+	pos      Position     // Source position
+	Elements []Expression // Targets of the leading elements
+	Rest     Expression   // Target of an array of the remaining elements (optional)
+}
+
+func (e *ArrayTarget) Position() Position { return e.pos }
+
+// String returns a string representation of the array target.
+func (e *ArrayTarget) String() string {
+	elements := []string{}
+	for _, element := range e.Elements {
+		elements = append(elements, element.String())
+	}
+	if e.Rest != nil {
+		elements = append(elements, "..."+e.Rest.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+}
+
+// ObjectTarget represents an object destructuring target {key: target},
+// which assigns the values of object keys to targets.
+type ObjectTarget struct {
+	pos    Position     // Source position
+	Keys   []string     // Keys to read from the object
+	Values []Expression // Targets of the values of the keys
+}
+
+func (e *ObjectTarget) Position() Position { return e.pos }
+
+// String returns a string representation of the object target.
+func (e *ObjectTarget) String() string {
+	items := []string{}
+	for i, key := range e.Keys {
+		if v, ok := e.Values[i].(*Variable); ok && v.Name == key {
+			items = append(items, key)
+		} else {
+			items = append(items, fmt.Sprintf("%q: %s", key, e.Values[i]))
+		}
+	}
+	return fmt.Sprintf("{%s}", strings.Join(items, ", "))
 }
 
 // Variable represents a variable reference.
 type Variable struct {
-	pos  Position // Source position
-	Name string   // Variable name
+	pos     Position // Source position
+	Name    string   // Variable name
+	binding binding  // Resolved location of the variable
 }
 
 func (e *Variable) Position() Position { return e.pos }
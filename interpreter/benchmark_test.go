@@ -0,0 +1,115 @@
+package interpreter
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchmarkEngines are the execution engines every benchmark is run on.
+var benchmarkEngines = []struct {
+	name   string
+	engine Engine
+}{
+	{"vm", EngineVM},
+	{"tree-walker", EngineTreeWalker},
+}
+
+// loopBenchmarkSource exercises tight loops with break/continue and
+// recursive calls with early returns.
+const loopBenchmarkSource = `
+fun collatz_steps(n):
+    steps = 0
+    while (true):
+        if (n == 1) then:
+            return steps
+        end
+        if (n % 2 == 0) then:
+            n = n / 2
+        else:
+            n = 3 * n + 1
+        end
+        steps += 1
+    end
+end
+
+fun count_primes(limit):
+    count = 0
+    for (n in range(2, limit)):
+        is_prime = true
+        d = 2
+        while (d * d <= n):
+            if (n % d == 0) then:
+                is_prime = false
+                break
+            end
+            d += 1
+        end
+        if (not is_prime) then:
+            continue
+        end
+        count += 1
+    end
+    return count
+end
+
+fun fib(n):
+    if (n < 2) then:
+        return n
+    end
+    return fib(n - 1) + fib(n - 2)
+end
+
+fun main():
+    total = 0
+    for (i in range(1, 300)):
+        total += collatz_steps(i)
+    end
+    print(total, count_primes(2000), fib(16))
+end
+`
+
+// benchmarkProgram parses source once and executes it b.N times per engine.
+func benchmarkProgram(b *testing.B, source []byte) {
+	prog, err := ParseProgram(source)
+	if err != nil {
+		b.Fatalf("Failed to parse program: %v", err)
+	}
+	for _, e := range benchmarkEngines {
+		b.Run(e.name, func(b *testing.B) {
+			config := &Config{Stdout: io.Discard, Engine: e.engine}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Execute(prog, config); err != nil {
+					b.Fatalf("Failed to execute program: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkExample runs one of the example scripts in the examples directory.
+func benchmarkExample(b *testing.B, name string) {
+	source, err := os.ReadFile(filepath.Join("..", "examples", name))
+	if err != nil {
+		b.Skipf("example %s not available: %v", name, err)
+	}
+	benchmarkProgram(b, source)
+}
+
+func BenchmarkLoops(b *testing.B) {
+	benchmarkProgram(b, []byte(loopBenchmarkSource))
+}
+
+func BenchmarkNumberTheoryDemo(b *testing.B) {
+	benchmarkExample(b, "10_number_theory_demo.din")
+}
+
+func BenchmarkLoopControlDemo(b *testing.B) {
+	benchmarkExample(b, "05_loop_control_demo.din")
+}
+
+func BenchmarkStatisticsAnalysis(b *testing.B) {
+	benchmarkExample(b, "08_statistics_analysis.din")
+}
@@ -0,0 +1,239 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// opcode identifies a single VM instruction.
+type opcode uint8
+
+const (
+	opNop opcode = iota
+
+	// Stack manipulation
+	opConst // push constants[a]
+	opPop   // discard top of stack
+
+	// Variables
+	opLoadLocal   // push local slot a
+	opLoadOuter   // push slot b of the environment a levels up
+	opLoadGlobal  // push global names[a]
+	opStoreLocal  // pop value into local slot a
+	opStoreOuter  // pop value into slot b of the environment a levels up
+	opStoreGlobal // pop value into global names[a]
+	opCompound    // pop current value and right operand; push the result of compound operator a
+	opDestructure // pop value; push the values targets[a] assigns, last first
+	opRotate      // move the value a places below the top of the stack to the top
+
+	// Operators
+	opBinary     // pop r, l; push binaryEvalFuncs[a](l, r)
+	opXor        // pop r, l; push truthiness xor
+	opUnary      // pop v; push unaryEvalFuncs[a](v)
+	opAndJump    // pop l (must be bool); if false push false and jump to a
+	opOrJump     // pop l (must be bool); if true push true and jump to a
+	opCoalesce   // jump to a if top of stack isn't null, else pop it
+	opAssertBool // ensure top of stack is bool for operator a
+
+	// Containers
+	opMakeList         // pop a values and push them as an array
+	opMakeMap          // pop a key/value pairs and push them as an object
+	opListAppend       // pop value and append it to the array on top of the stack
+	opListExtend       // pop iterable and append its values to the array on top of the stack
+	opMapSet           // pop value, key and set the key in the object on top of the stack
+	opMapMerge         // pop object and set its keys in the object on top of the stack
+	opInterpolate      // pop a values and push their string forms joined together
+	opMakeSlice        // pop step, end, start; push them as the bounds of a slice subscript
+	opSubscript        // pop subscript, container; push container[subscript], or null for a missing key if a is set
+	opStoreSubscript   // pop value, subscript, container; apply operator a and store
+	opMakeFunction     // push a closure for functions[a]
+	opCheckCallable    // ensure top of stack is a function
+	opCall             // pop a args and a function; push the result
+	opCallEllipsis     // like opCall, but unpack the last argument
+	opCallNamed        // like opCall, with the named arguments of namedCalls[b] last
+	opReturn           // return top of stack from the current function
+	opThrow            // pop a value and raise it as an error
+	opYield            // pop a value and suspend the generator frame, returning the value to its caller
+	opGetIter          // pop iterable; push iterator, over entries if b is set
+	opForIter          // advance iterator at top of stack, pushing the value and then the key if b is set, or jump to a
+	opJump             // jump to a
+	opJumpIfFalse      // pop condition (must be bool for statement kind b); jump to a if false
+	opJumpIfNotTruthy  // pop condition; jump to a if it is not truthy
+	opJumpIfBound      // jump to a if local slot b has been assigned
+	opJumpIfNull       // jump to a if top of stack is null, leaving it there
+	opJumpIfNotNull    // pop value; jump to a if it isn't null
+	opJumpIfSubscript  // jump to a, popping subscript and container, if container[subscript] isn't null
+	opSetupTry         // register a catch handler at a
+	opMatch            // match top of stack against patterns[b], binding captures; jump to a if no match
+	opEnterScope       // run in a new environment for scopes[a]
+	opExitScope        // return to the enclosing environment
+	opPopTry           // unregister the innermost catch handler
+	opImport           // execute the file named names[a] in the current scope
+	opBreakOutsideLoop // raise the break exception outside of any loop
+	opContinueOutside  // raise the continue exception outside of any loop
+)
+
+var opcodeNames = [...]string{
+	opNop:              "NOP",
+	opConst:            "CONST",
+	opPop:              "POP",
+	opLoadLocal:        "LOAD_LOCAL",
+	opLoadOuter:        "LOAD_OUTER",
+	opLoadGlobal:       "LOAD_GLOBAL",
+	opStoreLocal:       "STORE_LOCAL",
+	opStoreOuter:       "STORE_OUTER",
+	opStoreGlobal:      "STORE_GLOBAL",
+	opCompound:         "COMPOUND",
+	opDestructure:      "DESTRUCTURE",
+	opRotate:           "ROTATE",
+	opBinary:           "BINARY",
+	opXor:              "XOR",
+	opUnary:            "UNARY",
+	opAndJump:          "AND_JUMP",
+	opOrJump:           "OR_JUMP",
+	opCoalesce:         "COALESCE",
+	opAssertBool:       "ASSERT_BOOL",
+	opMakeList:         "MAKE_LIST",
+	opMakeMap:          "MAKE_MAP",
+	opListAppend:       "LIST_APPEND",
+	opListExtend:       "LIST_EXTEND",
+	opMapSet:           "MAP_SET",
+	opMapMerge:         "MAP_MERGE",
+	opInterpolate:      "INTERPOLATE",
+	opMakeSlice:        "MAKE_SLICE",
+	opSubscript:        "SUBSCRIPT",
+	opStoreSubscript:   "STORE_SUBSCRIPT",
+	opMakeFunction:     "MAKE_FUNCTION",
+	opCheckCallable:    "CHECK_CALLABLE",
+	opCall:             "CALL",
+	opCallEllipsis:     "CALL_ELLIPSIS",
+	opCallNamed:        "CALL_NAMED",
+	opReturn:           "RETURN",
+	opThrow:            "THROW",
+	opYield:            "YIELD",
+	opGetIter:          "GET_ITER",
+	opForIter:          "FOR_ITER",
+	opJump:             "JUMP",
+	opJumpIfFalse:      "JUMP_IF_FALSE",
+	opJumpIfNotTruthy:  "JUMP_IF_NOT_TRUTHY",
+	opJumpIfBound:      "JUMP_IF_BOUND",
+	opJumpIfNull:       "JUMP_IF_NULL",
+	opJumpIfNotNull:    "JUMP_IF_NOT_NULL",
+	opJumpIfSubscript:  "JUMP_IF_SUBSCRIPT",
+	opSetupTry:         "SETUP_TRY",
+	opMatch:            "MATCH",
+	opEnterScope:       "ENTER_SCOPE",
+	opExitScope:        "EXIT_SCOPE",
+	opPopTry:           "POP_TRY",
+	opImport:           "IMPORT",
+	opBreakOutsideLoop: "BREAK_OUTSIDE_LOOP",
+	opContinueOutside:  "CONTINUE_OUTSIDE_LOOP",
+}
+
+func (op opcode) String() string {
+	if int(op) < len(opcodeNames) && opcodeNames[op] != "" {
+		return opcodeNames[op]
+	}
+	return fmt.Sprintf("OP(%d)", op)
+}
+
+// instruction is a single decoded VM instruction. Operands a and b are
+// interpreted per opcode; pos is the source position used for errors.
+type instruction struct {
+	op opcode
+	// ops is the number of AST nodes whose evaluation this instruction
+	// accounts for, so the VM reports the same Stats.Ops as the tree-walker
+	ops int32
+	a   int32
+	b   int32
+	pos Position
+}
+
+// funcProto is the compiled form of a function definition or expression.
+type funcProto struct {
+	name       string
+	parameters []string
+	defaults   []Expression
+	ellipsis   bool
+	body       Block
+	prologue   Block
+	scope      *scope
+	code       *codeObject
+}
+
+// namedCall describes the arguments of a call with named arguments, which
+// follow its positional arguments on the stack.
+type namedCall struct {
+	names []string
+	// ellipsis is set if the last positional argument is unpacked, with
+	// ellipsisPos the position of that argument
+	ellipsis    bool
+	ellipsisPos Position
+}
+
+// codeObject holds the bytecode for a program, an imported file or a function body.
+type codeObject struct {
+	name         string
+	instructions []instruction
+	constants    []Value
+	names        []string
+	functions    []*funcProto
+	// locals holds the names of the local variable slots of a function
+	locals []string
+	// auxPos holds secondary source positions referenced by operand b
+	auxPos []Position
+	// patterns holds the patterns of match statements
+	patterns []Pattern
+	// targets holds the targets of destructuring assignments
+	targets []Expression
+	// namedCalls holds the named arguments of calls that have some
+	namedCalls []namedCall
+	// scopes holds the scopes of match arms with captures. Operand b of
+	// local variable instructions in an arm is the index of its scope plus 1.
+	scopes []*scope
+}
+
+// String returns a human readable disassembly of the code object.
+func (c *codeObject) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "== %s ==\n", c.name)
+	for i, ins := range c.instructions {
+		fmt.Fprintf(&sb, "%04d %4d:%-3d %-22s", i, ins.pos.Line, ins.pos.Column, ins.op)
+		switch ins.op {
+		case opConst:
+			fmt.Fprintf(&sb, " %d (%s)", ins.a, toString(c.constants[ins.a], true))
+		case opLoadGlobal, opStoreGlobal, opImport:
+			fmt.Fprintf(&sb, " %d (%s)", ins.a, c.names[ins.a])
+		case opLoadLocal, opStoreLocal:
+			locals := c.locals
+			if ins.b > 0 {
+				locals = c.scopes[ins.b-1].names
+			}
+			fmt.Fprintf(&sb, " %d (%s)", ins.a, locals[ins.a])
+		case opMatch:
+			fmt.Fprintf(&sb, " %d (%s)", ins.a, c.patterns[ins.b])
+		case opDestructure:
+			fmt.Fprintf(&sb, " %d (%s)", ins.a, c.targets[ins.a])
+		case opLoadOuter, opStoreOuter:
+			fmt.Fprintf(&sb, " %d %d", ins.a, ins.b)
+		case opJumpIfBound:
+			fmt.Fprintf(&sb, " %d (%s)", ins.a, c.locals[ins.b])
+		case opCallNamed:
+			fmt.Fprintf(&sb, " %d (%s)", ins.a, strings.Join(c.namedCalls[ins.b].names, ", "))
+		case opBinary, opUnary, opAssertBool, opStoreSubscript, opCompound:
+			fmt.Fprintf(&sb, " %s", Token(ins.a))
+		case opMakeFunction:
+			fmt.Fprintf(&sb, " %d (%s)", ins.a, c.functions[ins.a].name)
+		case opNop, opPop, opXor, opMakeSlice, opCheckCallable, opListAppend, opListExtend, opMapSet, opMapMerge,
+			opReturn, opThrow, opYield, opGetIter, opPopTry, opExitScope, opBreakOutsideLoop, opContinueOutside:
+		default:
+			fmt.Fprintf(&sb, " %d", ins.a)
+		}
+		sb.WriteString("\n")
+	}
+	for _, f := range c.functions {
+		sb.WriteString("\n")
+		sb.WriteString(f.code.String())
+	}
+	return sb.String()
+}
@@ -0,0 +1,95 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// callFrame records a user function call in progress.
+type callFrame struct {
+	function string   // name of the called function
+	pos      Position // position of the call
+}
+
+// StackFrame is an entry in the traceback attached to a runtime error.
+type StackFrame struct {
+	Function string   // name of the function, or "<module>" for top-level code
+	Position Position // position being executed when the error was raised
+}
+
+// maxChainEntries is the number of entries of a call chain shown in a
+// recursion error before the middle is elided.
+const maxChainEntries = 10
+
+// enterCall records a call to f made at pos. It stops execution with a
+// LimitError if the call exceeds Config.MaxCallDepth, and raises a
+// RuntimeError if it exceeds the maximum recursion depth.
+func (interp *interpreter) enterCall(f *userFunction, pos Position) {
+	interp.calls = append(interp.calls, callFrame{f.Name, pos})
+	depth := len(interp.calls)
+	if interp.limits.maxCallDepth > 0 && depth > interp.limits.maxCallDepth {
+		panic(limitError(pos, nil, "maximum call depth of %d exceeded", interp.limits.maxCallDepth))
+	}
+	if depth > interp.limits.maxRecursion {
+		chain := formatCallChain(interp.calls)
+		interp.exitCall()
+		panic(runtimeError(pos, "maximum recursion depth exceeded (%s)", chain))
+	}
+}
+
+// exitCall removes the innermost call recorded by enterCall.
+func (interp *interpreter) exitCall() {
+	interp.calls = interp.calls[:len(interp.calls)-1]
+}
+
+// traceback returns the stack of calls in progress, outermost first, with
+// the innermost frame at pos.
+func (interp *interpreter) traceback(pos Position) []StackFrame {
+	stack := make([]StackFrame, 0, len(interp.calls)+1)
+	function := "<module>"
+	for _, call := range interp.calls {
+		stack = append(stack, StackFrame{function, call.pos})
+		function = call.function
+		if function == "" {
+			function = "<fun>"
+		}
+	}
+	return append(stack, StackFrame{function, pos})
+}
+
+// attachStack adds the current traceback to a runtime error that doesn't
+// have one yet. Other recovered values are returned unchanged.
+func (interp *interpreter) attachStack(r any) any {
+	if e, ok := r.(tracedError); ok && e.Stack() == nil {
+		return e.withStack(interp.traceback(e.Position()))
+	}
+	return r
+}
+
+// formatCallChain returns the names of the functions in calls, outermost
+// first. Consecutive calls to the same function are collapsed into one
+// entry with a count, and long chains keep only their ends.
+func formatCallChain(calls []callFrame) string {
+	var entries []string
+	for i := 0; i < len(calls); {
+		j := i
+		for j < len(calls) && calls[j].function == calls[i].function {
+			j++
+		}
+		name := calls[i].function
+		if name == "" {
+			name = "<fun>"
+		}
+		if j-i > 1 {
+			name = fmt.Sprintf("%s (x%d)", name, j-i)
+		}
+		entries = append(entries, name)
+		i = j
+	}
+	if len(entries) > maxChainEntries {
+		half := maxChainEntries / 2
+		omitted := fmt.Sprintf("... %d more ...", len(entries)-2*half)
+		entries = append(append(entries[:half:half], omitted), entries[len(entries)-half:]...)
+	}
+	return strings.Join(entries, " -> ")
+}
@@ -0,0 +1,204 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRecursionDepthExceeded(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		config  Config
+		message string
+	}{
+		{
+			name:    "default_limit",
+			program: "fun down(n):\n    return down(n + 1)\nend\ndown(0)",
+			message: "runtime error at 2:12: maximum recursion depth exceeded (down (x10001))",
+		},
+		{
+			name: "configured_limit",
+			program: `fun even(n):
+    return n == 0 ? true : odd(n - 1)
+end
+fun odd(n):
+    return n == 0 ? false : even(n - 1)
+end
+even(100)`,
+			config:  Config{MaxRecursionDepth: 20},
+			message: "maximum recursion depth exceeded (even -> odd -> even -> odd -> even -> ... 11 more ... -> even -> odd -> even -> odd -> even)",
+		},
+		{
+			name:    "call_depth_limit_takes_precedence",
+			program: "fun down(n):\n    return down(n + 1)\nend\ndown(0)",
+			config:  Config{MaxCallDepth: 30, MaxRecursionDepth: 30},
+			message: "limit error at 2:12: maximum call depth of 30 exceeded",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for engine, err := range executeWithLimits(t, test.program, test.config) {
+				if err == nil || !strings.Contains(err.Error(), test.message) {
+					t.Errorf("Expected error containing %q on %s, got %v", test.message, engine, err)
+				}
+			}
+		})
+	}
+
+	errs := executeWithLimits(t, "fun f():\n    return f()\nend\nf()", Config{MaxRecursionDepth: 5})
+	for engine, err := range errs {
+		var runtimeErr RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("Expected RuntimeError on %s, got %v", engine, err)
+		}
+	}
+}
+
+func TestRecursionDepthCaughtByTry(t *testing.T) {
+	program := `
+	fun forever(n):
+		return forever(n + 1)
+	end
+	fun guarded():
+		try:
+			forever(0)
+		catch (e):
+			return "caught"
+		end
+	end
+	print(guarded())
+	print(guarded())
+	fun depth(n):
+		return n == 0 ? 0 : 1 + depth(n - 1)
+	end
+	print(depth(40))
+	`
+	for _, engine := range []Engine{EngineVM, EngineTreeWalker} {
+		prog, err := ParseProgram([]byte(program))
+		if err != nil {
+			t.Fatalf("Failed to parse program: %v", err)
+		}
+		var output strings.Builder
+		_, err = Execute(prog, &Config{Stdout: &output, Engine: engine, MaxRecursionDepth: 50})
+		if err != nil {
+			t.Fatalf("Failed to execute program on engine %d: %v", engine, err)
+		}
+		if output.String() != "caught\ncaught\n40\n" {
+			t.Errorf("Unexpected output on engine %d: %q", engine, output.String())
+		}
+	}
+}
+
+func TestRuntimeErrorTraceback(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.din")
+	if err := os.WriteFile(lib, []byte("fun divide(a, b):\n    return a / b\nend\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write library: %v", err)
+	}
+	program := fmt.Sprintf(`import %q
+fun average(xs):
+    return divide(len(xs), 0)
+end
+try:
+    divide(1, "x")
+catch (e):
+    print("caught")
+end
+average([1, 2])`, lib)
+
+	expected := []StackFrame{
+		{"<module>", Position{Line: 10, Column: 1}},
+		{"average", Position{Line: 3, Column: 12}},
+		{"divide", Position{Line: 2, Column: 14, File: lib}},
+	}
+	for _, e := range benchmarkEngines {
+		engine := e.name
+		prog, err := ParseProgram([]byte(program))
+		if err != nil {
+			t.Fatalf("Failed to parse program: %v", err)
+		}
+		_, err = Execute(prog, &Config{Stdout: io.Discard, Engine: e.engine})
+		traced, ok := err.(tracedError)
+		if !ok {
+			t.Fatalf("Expected runtime error on %s, got %v", engine, err)
+		}
+		if !reflect.DeepEqual(traced.Stack(), expected) {
+			t.Errorf("Unexpected traceback on %s: %+v", engine, traced.Stack())
+		}
+
+		output := FormatProgramError(err, prog, []byte(program), "main.din")
+		for _, line := range []string{
+			"Traceback (most recent call last):\n",
+			"  File \"main.din\", line 10, column 1, in <module>\n",
+			"average([1, 2])\n",
+			"  File \"main.din\", line 3, column 12, in average\n",
+			fmt.Sprintf("  File %q, line 2, column 14, in divide\n", lib),
+			lib + ":2:14: Value Error: value error at " + lib + ":2:14: can't divide by zero\n",
+			"    return a / b\n",
+		} {
+			if !strings.Contains(output, line) {
+				t.Errorf("Expected %q in formatted error on %s, got:\n%s", line, engine, output)
+			}
+		}
+
+		// The imported source is kept by the program that imported it
+		other, _ := ParseProgram([]byte(program))
+		if output := FormatProgramError(err, other, []byte(program), "main.din"); strings.Contains(output, "return a / b") {
+			t.Errorf("Expected no imported source for a program that didn't run on %s, got:\n%s", engine, output)
+		}
+		if output := FormatExecutionError(err, []byte(program), "main.din"); !strings.Contains(output, "average([1, 2])\n") ||
+			strings.Contains(output, "return a / b") {
+			t.Errorf("Expected only the main program's source without a program on %s, got:\n%s", engine, output)
+		}
+	}
+}
+
+func TestTopLevelErrorHasNoTraceback(t *testing.T) {
+	success, output := RunProgram("x = 1\ny = x + nothing")
+	if success {
+		t.Fatalf("Expected execution to fail, got: %s", output)
+	}
+	if strings.Contains(output, "Traceback") {
+		t.Errorf("Expected no traceback for a top-level error, got: %s", output)
+	}
+	if !strings.Contains(output, "y = x + nothing\n") {
+		t.Errorf("Expected source line in output, got: %s", output)
+	}
+}
+
+func TestConcurrentExecutionsShareProgram(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.din")
+	if err := os.WriteFile(lib, []byte("fun divide(a, b):\n    return a / b\nend\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write library: %v", err)
+	}
+	prog, err := ParseProgram([]byte(fmt.Sprintf("import %q\nprint(divide(4, 2))\ndivide(1, 0)", lib)))
+	if err != nil {
+		t.Fatalf("Failed to parse program: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = Execute(prog, &Config{Stdout: io.Discard, Engine: benchmarkEngines[i%2].engine})
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if output := FormatProgramError(err, prog, nil, "main.din"); !strings.Contains(output, "    return a / b\n") {
+			t.Errorf("Expected imported source in error of execution %d, got:\n%s", i, output)
+		}
+	}
+}
@@ -0,0 +1,681 @@
+package interpreter
+
+import (
+	"fmt"
+)
+
+// loopState tracks the jump targets of the loop being compiled.
+type loopState struct {
+	continueTarget int   // instruction index continue jumps to
+	breakJumps     []int // jump instructions to patch with the loop exit
+	blocks         int   // len(compiler.blocks) when the loop started
+}
+
+// blockState describes a block that a break, continue or return jumping out
+// of it must leave explicitly: a try or catch block whose handler is
+// registered while its code runs, or a match arm running in its own scope.
+type blockState struct {
+	// finally is the finally block to run when leaving a try block, or nil
+	finally Block
+	// arm is set for match arms, which are left by exiting their scope
+	arm bool
+	// outerScope is the compiler scope enclosing a match arm
+	outerScope int32
+}
+
+// compiler translates the AST into bytecode for the VM. One compiler is used
+// per code object; function bodies are compiled by a nested compiler.
+type compiler struct {
+	code *codeObject
+	// pendingOps counts AST nodes not yet attributed to an instruction
+	pendingOps int32
+	constants  map[Value]int32
+	names      map[string]int32
+	loops      []*loopState
+	// blocks holds the enclosing blocks, innermost last, that a break,
+	// continue or return must leave explicitly
+	blocks []*blockState
+	// scope is the index plus 1 in code.scopes of the match arm scope
+	// being compiled, or 0 outside of match arms
+	scope      int32
+	isFunction bool
+	// chainJumps holds the jumps of the optional links of the optional
+	// chain being compiled, to patch with the end of the chain
+	chainJumps []int
+}
+
+func newCompiler(name string, isFunction bool) *compiler {
+	return &compiler{
+		code:       &codeObject{name: name},
+		constants:  make(map[Value]int32),
+		names:      make(map[string]int32),
+		isFunction: isFunction,
+	}
+}
+
+// compileProgram compiles a list of top-level statements into a code object.
+func compileProgram(name string, statements Block) *codeObject {
+	c := newCompiler(name, false)
+	c.block(statements)
+	c.emit(opConst, c.constant(nil), 0, Position{})
+	c.emit(opReturn, 0, 1, Position{})
+	return c.code
+}
+
+// compiled returns the bytecode of a program, compiling it the first time so
+// that running the program again doesn't compile it again.
+func (prog *Program) compiled() *codeObject {
+	code := prog.code.Load()
+	if code == nil {
+		code = compileProgram("<program>", prog.Statements)
+		prog.code.Store(code)
+	}
+	return code
+}
+
+// compileExpression compiles a single expression whose value is returned
+// when the code object finishes.
+func compileExpression(expr Expression) *codeObject {
+	c := newCompiler("<expression>", false)
+	c.expression(expr)
+	c.emit(opReturn, 0, 1, expr.Position())
+	return c.code
+}
+
+// compileFunction compiles a function body into a function prototype.
+func compileFunction(name string, parameters []string, defaults []Expression, ellipsis bool, body, prologue Block, scope *scope) *funcProto {
+	codeName := name
+	if codeName == "" {
+		codeName = "<fun>"
+	}
+	c := newCompiler(codeName, true)
+	c.code.locals = scope.names
+	c.block(prologue)
+	if scope.generator && len(prologue) != 0 {
+		// A generator runs its prologue when it is created and stops
+		// before its body, which runs on the first call to next
+		c.emit(opConst, c.constant(nil), 0, Position{})
+		c.emit(opYield, 0, 0, Position{})
+	}
+	c.block(body)
+	c.emit(opConst, c.constant(nil), 0, Position{})
+	c.emit(opReturn, 0, 1, Position{})
+	return &funcProto{name, parameters, defaults, ellipsis, body, prologue, scope, c.code}
+}
+
+// emit appends an instruction and returns its index. Any pending op count is
+// attributed to the new instruction.
+func (c *compiler) emit(op opcode, a, b int32, pos Position) int {
+	c.code.instructions = append(c.code.instructions, instruction{op, c.pendingOps, a, b, pos})
+	c.pendingOps = 0
+	return len(c.code.instructions) - 1
+}
+
+// label returns the index of the next instruction as a backward jump target.
+// Pending op counts are flushed first so they are not repeated on every jump.
+func (c *compiler) label() int {
+	if c.pendingOps > 0 {
+		c.emit(opNop, 0, 0, Position{})
+	}
+	return len(c.code.instructions)
+}
+
+// patch points the jump instruction at index to the next instruction.
+func (c *compiler) patch(index int) {
+	c.code.instructions[index].a = int32(len(c.code.instructions))
+}
+
+func (c *compiler) constant(v Value) int32 {
+	if i, ok := c.constants[v]; ok {
+		return i
+	}
+	c.code.constants = append(c.code.constants, v)
+	i := int32(len(c.code.constants) - 1)
+	c.constants[v] = i
+	return i
+}
+
+func (c *compiler) name(name string) int32 {
+	if i, ok := c.names[name]; ok {
+		return i
+	}
+	c.code.names = append(c.code.names, name)
+	i := int32(len(c.code.names) - 1)
+	c.names[name] = i
+	return i
+}
+
+func (c *compiler) auxPos(pos Position) int32 {
+	c.code.auxPos = append(c.code.auxPos, pos)
+	return int32(len(c.code.auxPos) - 1)
+}
+
+func (c *compiler) function(name string, parameters []string, defaults []Expression, ellipsis bool, body, prologue Block, scope *scope) int32 {
+	c.code.functions = append(c.code.functions, compileFunction(name, parameters, defaults, ellipsis, body, prologue, scope))
+	return int32(len(c.code.functions) - 1)
+}
+
+// load emits the instruction that pushes the value of a resolved variable.
+func (c *compiler) load(b binding, name string, pos Position) {
+	switch b.depth {
+	case globalDepth:
+		c.emit(opLoadGlobal, c.name(name), 0, pos)
+	case 0:
+		c.emit(opLoadLocal, int32(b.index), c.scope, pos)
+	default:
+		c.emit(opLoadOuter, int32(b.depth), int32(b.index), pos)
+	}
+}
+
+// store emits the instruction that pops a value into a resolved variable.
+func (c *compiler) store(b binding, name string, pos Position) {
+	switch b.depth {
+	case globalDepth:
+		c.emit(opStoreGlobal, c.name(name), 0, pos)
+	case 0:
+		c.emit(opStoreLocal, int32(b.index), c.scope, pos)
+	default:
+		c.emit(opStoreOuter, int32(b.depth), int32(b.index), pos)
+	}
+}
+
+func (c *compiler) block(block Block) {
+	for _, s := range block {
+		c.statement(s)
+	}
+}
+
+func (c *compiler) statement(s Statement) {
+	c.pendingOps++
+	switch s := s.(type) {
+	case *Assign:
+		switch target := s.Target.(type) {
+		case *Variable:
+			if s.Operator == NULLISHEQUAL {
+				c.load(target.binding, target.Name, s.Value.Position())
+				jump := c.emit(opJumpIfNotNull, 0, 0, s.Position())
+				c.expression(s.Value)
+				c.store(target.binding, target.Name, s.Position())
+				c.patch(jump)
+				break
+			}
+			c.expression(s.Value)
+			if s.Operator != ASSIGN {
+				c.load(target.binding, target.Name, s.Value.Position())
+				c.emit(opCompound, int32(s.Operator), 0, s.Value.Position())
+			}
+			c.store(target.binding, target.Name, s.Position())
+		case *Subscript:
+			c.expression(target.Container)
+			c.expression(target.Subscript)
+			if s.Operator == NULLISHEQUAL {
+				jump := c.emit(opJumpIfSubscript, 0, 0, s.Value.Position())
+				c.expression(s.Value)
+				c.emit(opStoreSubscript, int32(ASSIGN), 0, target.Subscript.Position())
+				c.patch(jump)
+				break
+			}
+			c.expression(s.Value)
+			c.emit(opStoreSubscript, int32(s.Operator), c.auxPos(s.Value.Position()), target.Subscript.Position())
+		case *ArrayTarget, *ObjectTarget:
+			c.expression(s.Value)
+			c.assignTarget(target, s.Position())
+		default:
+			// Parser should never get us here
+			panic("can only assign to variable or subscript")
+		}
+	case *If:
+		c.expression(s.Condition)
+		jumpElse := c.emit(opJumpIfFalse, 0, int32(IF), s.Condition.Position())
+		c.block(s.Body)
+		if len(s.Else) > 0 {
+			jumpEnd := c.emit(opJump, 0, 0, s.Position())
+			c.patch(jumpElse)
+			c.block(s.Else)
+			c.patch(jumpEnd)
+		} else {
+			c.patch(jumpElse)
+		}
+	case *While:
+		loop := &loopState{blocks: len(c.blocks)}
+		loop.continueTarget = c.label()
+		c.expression(s.Condition)
+		jumpExit := c.emit(opJumpIfFalse, 0, int32(WHILE), s.Condition.Position())
+		c.loopBody(loop, s.Body)
+		c.emit(opJump, int32(loop.continueTarget), 0, s.Position())
+		c.patch(jumpExit)
+		for _, j := range loop.breakJumps {
+			c.patch(j)
+		}
+	case *For:
+		entries := int32(0)
+		if s.Key != "" {
+			entries = 1
+		}
+		c.expression(s.Iterable)
+		c.emit(opGetIter, 0, entries, s.Iterable.Position())
+		loop := &loopState{blocks: len(c.blocks)}
+		loop.continueTarget = c.label()
+		jumpExit := c.emit(opForIter, 0, entries, s.Position())
+		if s.Key != "" {
+			c.store(s.keyBinding, s.Key, s.Position())
+		}
+		if s.Target != nil {
+			c.assignTarget(s.Target, s.Position())
+		} else {
+			c.store(s.binding, s.Name, s.Position())
+		}
+		c.loopBody(loop, s.Body)
+		c.emit(opJump, int32(loop.continueTarget), 0, s.Position())
+		// Both exhaustion and break leave the iterator on the stack
+		c.patch(jumpExit)
+		for _, j := range loop.breakJumps {
+			c.patch(j)
+		}
+		c.emit(opPop, 0, 0, s.Position())
+	case *Match:
+		c.match(s)
+	case *TryCatch:
+		setup := c.emit(opSetupTry, 0, 0, s.Position())
+		c.tryBlock(s.TryBlock, s.FinallyBlock)
+		c.emit(opPopTry, 0, 0, s.Position())
+		jumpEnd := c.emit(opJump, 0, 0, s.Position())
+		// The VM jumps here with the caught error on the stack
+		c.patch(setup)
+		if s.ErrVar == "" {
+			// Without a catch block, the error runs the finally block and is
+			// then raised again
+			c.block(s.FinallyBlock)
+			c.emit(opThrow, 0, 0, s.Position())
+			c.patch(jumpEnd)
+			c.block(s.FinallyBlock)
+			return
+		}
+		c.store(s.errBinding, s.ErrVar, s.Position())
+		if s.FinallyBlock == nil {
+			c.block(s.CatchBlock)
+			c.patch(jumpEnd)
+			return
+		}
+		setupCatch := c.emit(opSetupTry, 0, 0, s.Position())
+		c.tryBlock(s.CatchBlock, s.FinallyBlock)
+		c.emit(opPopTry, 0, 0, s.Position())
+		jumpFinally := c.emit(opJump, 0, 0, s.Position())
+		// An error raised by the catch block runs the finally block and is
+		// then raised again
+		c.patch(setupCatch)
+		c.block(s.FinallyBlock)
+		c.emit(opThrow, 0, 0, s.Position())
+		c.patch(jumpEnd)
+		c.patch(jumpFinally)
+		c.block(s.FinallyBlock)
+	case *ExpressionStatement:
+		c.expression(s.Expression)
+		c.emit(opPop, 0, 0, s.Position())
+	case *FunctionDefinition:
+		c.emit(opMakeFunction, c.function(s.Name, s.Parameters, s.Defaults, s.Ellipsis, s.Body, s.prologue, s.scope), 0, s.Position())
+		c.store(s.binding, s.Name, s.Position())
+	case *Return:
+		c.expression(s.Result)
+		if c.hasFinally() {
+			c.unwindBlocks(0, s.Position())
+		}
+		c.emit(opReturn, 0, 0, s.Position())
+	case *Throw:
+		c.expression(s.Value)
+		c.emit(opThrow, 0, 0, s.Position())
+	case *Element:
+		// The comprehension keeps the array or object it builds in slot 0
+		c.emit(opLoadLocal, 0, c.scope, s.Position())
+		if s.Key != nil {
+			c.expression(s.Key)
+			c.expression(s.Value)
+			c.emit(opMapSet, 0, 0, s.Key.Position())
+		} else {
+			c.expression(s.Value)
+			c.emit(opListAppend, 0, 0, s.Position())
+		}
+		c.emit(opPop, 0, 0, s.Position())
+	case *DefaultValue:
+		jumpBound := c.emit(opJumpIfBound, 0, int32(s.Parameter.binding.index), s.Position())
+		c.expression(s.Value)
+		c.store(s.Parameter.binding, s.Parameter.Name, s.Position())
+		c.patch(jumpBound)
+	case *Yield:
+		c.expression(s.Value)
+		c.emit(opYield, 0, 0, s.Position())
+	case *Break:
+		if len(c.loops) == 0 {
+			c.emit(opBreakOutsideLoop, 0, 0, s.Position())
+			return
+		}
+		loop := c.loops[len(c.loops)-1]
+		c.unwindBlocks(loop.blocks, s.Position())
+		loop.breakJumps = append(loop.breakJumps, c.emit(opJump, 0, 0, s.Position()))
+	case *Continue:
+		if len(c.loops) == 0 {
+			c.emit(opContinueOutside, 0, 0, s.Position())
+			return
+		}
+		loop := c.loops[len(c.loops)-1]
+		c.unwindBlocks(loop.blocks, s.Position())
+		c.emit(opJump, int32(loop.continueTarget), 0, s.Position())
+	case *Import:
+		c.emit(opImport, c.name(s.Filename), 0, s.Position())
+	case *NameDeclaration:
+		// Only counted as an operation; the resolver has applied it
+		c.emit(opNop, 0, 0, s.Position())
+	default:
+		// Parser should never get us here
+		panic(fmt.Sprintf("unexpected statement type %T", s))
+	}
+}
+
+// assignTarget emits the instructions that pop a value into a variable, a
+// subscript or a destructuring target.
+func (c *compiler) assignTarget(target Expression, pos Position) {
+	switch t := target.(type) {
+	case *Variable:
+		c.store(t.binding, t.Name, pos)
+	case *Subscript:
+		c.expression(t.Container)
+		c.expression(t.Subscript)
+		c.emit(opRotate, 2, 0, pos)
+		c.emit(opStoreSubscript, int32(ASSIGN), 0, t.Subscript.Position())
+	default:
+		c.code.targets = append(c.code.targets, target)
+		c.emit(opDestructure, int32(len(c.code.targets)-1), 0, pos)
+		for _, leaf := range targetLeaves(target, nil) {
+			c.assignTarget(leaf, pos)
+		}
+	}
+}
+
+// loopBody compiles the body of a loop with loop as the break/continue target.
+func (c *compiler) loopBody(loop *loopState, body Block) {
+	c.loops = append(c.loops, loop)
+	c.block(body)
+	c.loops = c.loops[:len(c.loops)-1]
+}
+
+// tryBlock compiles the body of a try or catch block whose handler is
+// registered while it runs.
+func (c *compiler) tryBlock(body, finally Block) {
+	c.blocks = append(c.blocks, &blockState{finally: finally})
+	c.block(body)
+	c.blocks = c.blocks[:len(c.blocks)-1]
+}
+
+// hasFinally reports whether any enclosing try block has a finally block.
+func (c *compiler) hasFinally() bool {
+	for _, b := range c.blocks {
+		if b.finally != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// unwindBlocks emits the instructions needed to leave every block entered
+// since the first depth ones: exiting the scope of a match arm, or
+// unregistering the handler of a try block and running its finally block.
+func (c *compiler) unwindBlocks(depth int, pos Position) {
+	blocks, scope := c.blocks, c.scope
+	for i := len(blocks) - 1; i >= depth; i-- {
+		if blocks[i].arm {
+			c.emit(opExitScope, 0, 0, pos)
+			c.scope = blocks[i].outerScope
+			continue
+		}
+		c.emit(opPopTry, 0, 0, pos)
+		if blocks[i].finally != nil {
+			// Jumps out of the finally block only unwind the outer blocks
+			c.blocks = blocks[:i]
+			c.block(blocks[i].finally)
+		}
+	}
+	c.blocks, c.scope = blocks, scope
+}
+
+// match compiles a match statement. The subject stays on the stack until an
+// arm matches; an arm with captures runs in a new environment holding them.
+func (c *compiler) match(s *Match) {
+	c.expression(s.Subject)
+	jumpsEnd := []int{}
+	for i := range s.Cases {
+		arm := &s.Cases[i]
+		outerScope := c.scope
+		if arm.scope != nil {
+			c.code.scopes = append(c.code.scopes, arm.scope)
+			c.emit(opEnterScope, int32(len(c.code.scopes)-1), 0, s.Position())
+			c.scope = int32(len(c.code.scopes))
+		}
+		jumpsNext := []int{}
+		if arm.Pattern != nil {
+			c.code.patterns = append(c.code.patterns, arm.Pattern)
+			pattern := int32(len(c.code.patterns) - 1)
+			jumpsNext = append(jumpsNext, c.emit(opMatch, 0, pattern, arm.Pattern.Position()))
+		}
+		if arm.Guard != nil {
+			c.expression(arm.Guard)
+			jumpsNext = append(jumpsNext, c.emit(opJumpIfFalse, 0, int32(MATCH), arm.Guard.Position()))
+		}
+		// Pop the subject before running the body, which may jump out
+		c.emit(opPop, 0, 0, s.Position())
+		if arm.scope != nil {
+			c.blocks = append(c.blocks, &blockState{arm: true, outerScope: outerScope})
+			c.block(arm.Body)
+			c.blocks = c.blocks[:len(c.blocks)-1]
+			c.emit(opExitScope, 0, 0, s.Position())
+		} else {
+			c.block(arm.Body)
+		}
+		jumpsEnd = append(jumpsEnd, c.emit(opJump, 0, 0, s.Position()))
+		for _, j := range jumpsNext {
+			c.patch(j)
+		}
+		if arm.scope != nil && len(jumpsNext) > 0 {
+			c.emit(opExitScope, 0, 0, s.Position())
+		}
+		c.scope = outerScope
+	}
+	// No arm matched
+	c.emit(opPop, 0, 0, s.Position())
+	for _, j := range jumpsEnd {
+		c.patch(j)
+	}
+}
+
+func (c *compiler) expression(expr Expression) {
+	c.pendingOps++
+	switch e := expr.(type) {
+	case *Binary:
+		switch e.Operator {
+		case AND:
+			c.expression(e.Left)
+			jump := c.emit(opAndJump, 0, 0, e.Position())
+			c.expression(e.Right)
+			c.emit(opAssertBool, int32(AND), 0, e.Position())
+			c.patch(jump)
+		case OR:
+			c.expression(e.Left)
+			jump := c.emit(opOrJump, 0, 0, e.Position())
+			c.expression(e.Right)
+			c.emit(opAssertBool, int32(OR), 0, e.Position())
+			c.patch(jump)
+		case NULLISH:
+			c.expression(e.Left)
+			jump := c.emit(opCoalesce, 0, 0, e.Position())
+			c.expression(e.Right)
+			c.patch(jump)
+		case XOR:
+			c.expression(e.Left)
+			c.expression(e.Right)
+			c.emit(opXor, 0, 0, e.Position())
+		default:
+			if _, ok := binaryEvalFuncs[e.Operator]; !ok {
+				// Parser should never give us this
+				panic(fmt.Sprintf("unknown binary operator %v", e.Operator))
+			}
+			c.expression(e.Left)
+			c.expression(e.Right)
+			c.emit(opBinary, int32(e.Operator), 0, e.Position())
+		}
+	case *Unary:
+		if _, ok := unaryEvalFuncs[e.Operator]; !ok {
+			// Parser should never give us this
+			panic(fmt.Sprintf("unknown unary operator %v", e.Operator))
+		}
+		c.expression(e.Operand)
+		c.emit(opUnary, int32(e.Operator), 0, e.Position())
+	case *Ternary:
+		c.expression(e.Condition)
+		jumpElse := c.emit(opJumpIfNotTruthy, 0, 0, e.Position())
+		c.expression(e.TrueExpr)
+		jumpEnd := c.emit(opJump, 0, 0, e.Position())
+		c.patch(jumpElse)
+		c.expression(e.FalseExpr)
+		c.patch(jumpEnd)
+	case *Call:
+		c.expression(e.Function)
+		c.optionalLink(e.Optional, e.Position())
+		c.emit(opCheckCallable, 0, 0, e.Function.Position())
+		for _, arg := range e.Arguments {
+			c.expression(arg)
+		}
+		if len(e.Names) > 0 {
+			call := namedCall{names: e.Names, ellipsis: e.Ellipsis}
+			if e.Ellipsis {
+				call.ellipsisPos = e.Arguments[len(e.Arguments)-len(e.Names)-1].Position()
+			}
+			c.code.namedCalls = append(c.code.namedCalls, call)
+			c.emit(opCallNamed, int32(len(e.Arguments)), int32(len(c.code.namedCalls)-1), e.Function.Position())
+		} else if e.Ellipsis {
+			last := e.Arguments[len(e.Arguments)-1].Position()
+			c.emit(opCallEllipsis, int32(len(e.Arguments)), c.auxPos(last), e.Function.Position())
+		} else {
+			c.emit(opCall, int32(len(e.Arguments)), 0, e.Function.Position())
+		}
+	case *Literal:
+		c.emit(opConst, c.constant(e.Value), 0, e.Position())
+	case *Interpolation:
+		n := int32(0)
+		for i, str := range e.Strings {
+			if i > 0 {
+				c.expression(e.Values[i-1])
+				n++
+			}
+			if str != "" {
+				c.emit(opConst, c.constant(str), 0, e.Position())
+				n++
+			}
+		}
+		c.emit(opInterpolate, n, 0, e.Position())
+	case *Variable:
+		c.load(e.binding, e.Name, e.Position())
+	case *List:
+		// Elements from the first spread on are added one at a time
+		n := 0
+		for n < len(e.Values) && !isSpread(e.Values[n]) {
+			n++
+		}
+		for _, v := range e.Values[:n] {
+			c.expression(v)
+		}
+		c.emit(opMakeList, int32(n), 0, e.Position())
+		for _, v := range e.Values[n:] {
+			if spread, ok := v.(*Spread); ok {
+				c.expression(spread.Value)
+				c.emit(opListExtend, 0, 0, spread.Position())
+			} else {
+				c.expression(v)
+				c.emit(opListAppend, 0, 0, v.Position())
+			}
+		}
+	case *Map:
+		n := 0
+		for n < len(e.Items) && e.Items[n].Key != nil {
+			n++
+		}
+		first := int32(len(c.code.auxPos))
+		for _, item := range e.Items[:n] {
+			c.auxPos(item.Key.Position())
+		}
+		for _, item := range e.Items[:n] {
+			c.expression(item.Key)
+			c.expression(item.Value)
+		}
+		c.emit(opMakeMap, int32(n), first, e.Position())
+		for _, item := range e.Items[n:] {
+			if item.Key == nil {
+				spread := item.Value.(*Spread)
+				c.expression(spread.Value)
+				c.emit(opMapMerge, 0, 0, spread.Position())
+			} else {
+				c.expression(item.Key)
+				c.expression(item.Value)
+				c.emit(opMapSet, 0, 0, item.Key.Position())
+			}
+		}
+	case *Subscript:
+		c.expression(e.Container)
+		c.optionalLink(e.Optional, e.Position())
+		c.expression(e.Subscript)
+		optional := int32(0)
+		if e.Optional {
+			optional = 1
+		}
+		c.emit(opSubscript, optional, 0, e.Subscript.Position())
+	case *OptionalChain:
+		outer := c.chainJumps
+		c.chainJumps = nil
+		c.expression(e.Expression)
+		for _, jump := range c.chainJumps {
+			c.patch(jump)
+		}
+		c.chainJumps = outer
+	case *Slice:
+		for _, bound := range []Expression{e.Start, e.End, e.Step} {
+			if bound != nil {
+				c.expression(bound)
+			} else {
+				c.emit(opConst, c.constant(nil), 0, e.Position())
+			}
+		}
+		c.emit(opMakeSlice, 0, 0, e.Position())
+	case *Comprehension:
+		outerScope := c.scope
+		c.code.scopes = append(c.code.scopes, e.scope)
+		c.emit(opEnterScope, int32(len(c.code.scopes)-1), 0, e.Position())
+		c.scope = int32(len(c.code.scopes))
+		if e.Element.Key != nil {
+			c.emit(opMakeMap, 0, 0, e.Position())
+		} else {
+			c.emit(opMakeList, 0, 0, e.Position())
+		}
+		c.emit(opStoreLocal, 0, c.scope, e.Position())
+		c.statement(e.Loop)
+		c.emit(opLoadLocal, 0, c.scope, e.Position())
+		c.emit(opExitScope, 0, 0, e.Position())
+		c.scope = outerScope
+	case *FunctionExpression:
+		c.emit(opMakeFunction, c.function("", e.Parameters, e.Defaults, e.Ellipsis, e.Body, e.prologue, e.scope), 0, e.Position())
+	default:
+		// Parser should never get us here
+		panic(fmt.Sprintf("unexpected expression type %T", expr))
+	}
+}
+
+// optionalLink emits the jump to the end of the optional chain taken when
+// the container or function of an optional link is null.
+func (c *compiler) optionalLink(optional bool, pos Position) {
+	if optional {
+		c.chainJumps = append(c.chainJumps, c.emit(opJumpIfNull, 0, 0, pos))
+	}
+}
+
+// isSpread reports whether an element of a list literal is a spread.
+func isSpread(expr Expression) bool {
+	_, ok := expr.(*Spread)
+	return ok
+}
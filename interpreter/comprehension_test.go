@@ -0,0 +1,145 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComprehensions(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "array",
+			program: `
+			xs = [1, 2, 3, 4, 5, 6]
+			print([x * x for x in xs if x % 2 == 0], [c for c in "abc"], [x for x in []])`,
+			expected: "[4, 16, 36] [\"a\", \"b\", \"c\"] []\n",
+		},
+		{
+			name: "object",
+			program: `
+			obj = {a: 1, b: null, c: 3}
+			print({k: v for (k, v in obj) if v != null})
+			print({"${k}_${i}": i * 2 for i, k in ["x", "y"]}, {"key": 1 for x in range(3)})`,
+			expected: "{\"a\": 1, \"c\": 3}\n{\"x_0\": 0, \"y_1\": 2} {\"key\": 1}\n",
+		},
+		{
+			name: "multiple_clauses",
+			program: `
+			print([[i, j] for i in range(3) for j in range(i) if i + j > 1])
+			print([x for x in range(10) if x % 2 == 0 if x % 3 == 0])
+			print([[y for y in range(x)] for x in range(3)])`,
+			expected: "[[2, 0], [2, 1]]\n[0, 6]\n[[], [0], [0, 1]]\n",
+		},
+		{
+			name: "destructuring",
+			program: `
+			points = [{x: 1, y: 2}, {x: 3, y: 4}]
+			print([x + y for {x, y} in points], [a * b for ([a, b] in [[2, 3], [4, 5]])])`,
+			expected: "[3, 7] [6, 20]\n",
+		},
+		{
+			name: "variables_dont_leak",
+			program: `
+			fun f(xs):
+				x = "outer"
+				ys = [x * 2 for x in xs]
+				return [x, ys]
+			end
+			print(f([1, 2]))
+			x = "global"
+			print([x for x in "ab"], x)
+			print([i for i in range(2)])
+			try:
+				print(i)
+			catch (e):
+				print(e.message)
+			end`,
+			expected: "[\"outer\", [2, 4]]\n[\"a\", \"b\"] global\n[0, 1]\nname \"i\" not found\n",
+		},
+		{
+			name: "enclosing_variables",
+			program: `
+			fun scale(xs, factor):
+				return [x * factor for x in xs if x > 0]
+			end
+			fun rows(n):
+				for (i in range(n)):
+					yield [i * j for j in range(i + 1)]
+				end
+			end
+			print(scale([1, -2, 3], 10), rows(3)...)
+			match ([1, 2]):
+				case [a, b]:
+					print([a + b + x for x in range(2)])
+			end`,
+			expected: "[10, 30] [0] [0, 1] [0, 2, 4]\n[3, 4]\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestComprehensionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"not_iterable", "x = [y for y in 5]", "type error at 1:17: expected iterable (string, array, object or iterator), got integer"},
+		{"condition", "x = [y for y in [1] if y]", "type error at 1:24: if condition must be bool, got integer"},
+		{"key", "x = {y: 1 for y in [1]}", "type error at 1:6: object key must be string, not integer"},
+		{"caught", "try:\nx = [1 / y for y in [0]]\ncatch (e):\nprint(e.message)\nend\nx = [1 / y for y in [0]]", "value error at 6:8: can't divide by zero"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, test.program, test.message)
+		})
+	}
+}
+
+func TestParseComprehensions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[x * x for x in xs if x % 2 == 0]", "[(x * x) for x in xs if ((x % 2) == 0)]"},
+		{"{k: v for (k, v in obj) if v != null}", "{k: v for k, v in obj if (v != nil)}"},
+		{"{\"k\": v for v in xs}", "{\"k\": v for v in xs}"},
+		{"[a for [a, b] in pairs for c in a]", "[a for [a, b] in pairs for c in a]"},
+	}
+
+	for _, test := range tests {
+		expr, err := ParseExpression([]byte(test.input))
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", test.input, err)
+		}
+		if expr.String() != test.expected {
+			t.Errorf("Expected %q for %q, got %q", test.expected, test.input, expr.String())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"[x for x]", "parse error at 1:9: expected in, but got ]"},
+		{"[x for x in xs, y]", "parse error at 1:15: expected ], but got ,"},
+		{"[1, x for x in xs]", "parse error at 1:7: missing comma ',' between array elements"},
+		{"{a: 1, b: x for x in xs}", "parse error at 1:13: missing comma ',' between object properties"},
+	}
+	for _, test := range errors {
+		_, err := ParseExpression([]byte(test.input))
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected error containing %q for %q, got %v", test.message, test.input, err)
+		}
+	}
+}
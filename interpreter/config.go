@@ -1,16 +1,34 @@
 package interpreter
 
 import (
+	"context"
 	"io"
 	"os"
+	"time"
 )
 
+// Engine selects how the interpreter runs a parsed program.
+type Engine int
+
+const (
+	// EngineVM compiles the program to bytecode and runs it on a stack-based VM.
+	EngineVM Engine = iota
+	// EngineTreeWalker evaluates the AST directly. It is kept for comparison.
+	EngineTreeWalker
+)
+
+// DefaultMaxRecursionDepth is the recursion limit used when
+// Config.MaxRecursionDepth is zero.
+const DefaultMaxRecursionDepth = 10000
+
 // Config allows you to configure the interpreter's interaction with the
 // outside world. This provides a way to customize the environment in which
 // the interpreted code runs.
 type Config struct {
 	// Vars is a map of pre-defined variables to pass into the interpreter.
 	// These variables will be available to the interpreted code.
+	// Values of type map[string]Value are converted to objects, with their
+	// keys in sorted order.
 	Vars map[string]Value
 
 	// Args is the list of command-line arguments for the interpreter's args()
@@ -32,6 +50,31 @@ type Config struct {
 	// IsUnitTest menandakan bahwa interpreter sedang berjalan dalam konteks unit test
 	// Jika true, fungsi main() tidak akan dijalankan secara otomatis
 	IsUnitTest bool
+
+	// Engine selects the execution engine. The zero value runs programs on
+	// the bytecode VM.
+	Engine Engine
+
+	// Context, if not nil, stops execution with a LimitError when it is
+	// cancelled or its deadline passes.
+	Context context.Context
+
+	// MaxOps is the maximum number of operations (as counted by Stats.Ops)
+	// the program may execute. Zero means no limit.
+	MaxOps int
+
+	// MaxCallDepth is the maximum depth of nested user function calls.
+	// Zero means no limit.
+	MaxCallDepth int
+
+	// MaxRecursionDepth is the depth of nested user function calls at which
+	// a catchable "maximum recursion depth exceeded" RuntimeError is raised.
+	// Zero means DefaultMaxRecursionDepth.
+	MaxRecursionDepth int
+
+	// Timeout is the maximum wall-clock time the program may run for.
+	// Zero means no limit.
+	Timeout time.Duration
 }
 
 // DefaultConfig returns a configuration with sensible defaults
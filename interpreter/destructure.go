@@ -0,0 +1,88 @@
+package interpreter
+
+// destructure appends to values the values that a destructuring target
+// assigns, in the order of targetLeaves. The whole value is checked before
+// anything is assigned; a value that doesn't have the shape of the target
+// raises a ValueError at the position of the mismatched pattern.
+func destructure(target Expression, value Value, values []Value) []Value {
+	switch t := target.(type) {
+	case *ArrayTarget:
+		array, ok := value.(*[]Value)
+		if !ok {
+			panic(valueError(t.pos, "can't destructure %s as an array", typeName(value)))
+		}
+		elements := *array
+		if t.Rest == nil && len(elements) != len(t.Elements) {
+			panic(valueError(t.pos, "expected %d values to destructure, got %d", len(t.Elements), len(elements)))
+		}
+		if len(elements) < len(t.Elements) {
+			panic(valueError(t.pos, "expected at least %d values to destructure, got %d", len(t.Elements), len(elements)))
+		}
+		for i, element := range t.Elements {
+			values = destructure(element, elements[i], values)
+		}
+		if t.Rest != nil {
+			rest := append([]Value{}, elements[len(t.Elements):]...)
+			values = destructure(t.Rest, &rest, values)
+		}
+	case *ObjectTarget:
+		var fields *Object
+		switch v := value.(type) {
+		case *Object:
+			fields = v
+		case *errorObject:
+			fields = v.fields
+		default:
+			panic(valueError(t.pos, "can't destructure %s as an object", typeName(value)))
+		}
+		for i, key := range t.Keys {
+			v, ok := fields.Get(key)
+			if !ok {
+				panic(valueError(t.pos, "key %q not found in object to destructure", key))
+			}
+			values = destructure(t.Values[i], v, values)
+		}
+	default:
+		values = append(values, value)
+	}
+	return values
+}
+
+// targetLeaves appends to leaves the variables and subscripts a
+// destructuring target assigns to, from left to right.
+func targetLeaves(target Expression, leaves []Expression) []Expression {
+	switch t := target.(type) {
+	case *ArrayTarget:
+		for _, element := range t.Elements {
+			leaves = targetLeaves(element, leaves)
+		}
+		if t.Rest != nil {
+			leaves = targetLeaves(t.Rest, leaves)
+		}
+	case *ObjectTarget:
+		for _, value := range t.Values {
+			leaves = targetLeaves(value, leaves)
+		}
+	default:
+		leaves = append(leaves, target)
+	}
+	return leaves
+}
+
+// assignTarget assigns a value to a variable, a subscript or a
+// destructuring target.
+func (interp *interpreter) assignTarget(target Expression, value Value) {
+	switch t := target.(type) {
+	case *Variable:
+		interp.assignVariable(t.binding, t.Name, value)
+	case *Subscript:
+		container := interp.evaluate(t.Container)
+		subscript := interp.evaluate(t.Subscript)
+		interp.assignSubscript(t.Subscript.Position(), container, subscript, value)
+	default:
+		values := destructure(target, value, nil)
+		for i, leaf := range targetLeaves(target, nil) {
+			interp.assignTarget(leaf, values[i])
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDestructuringAssignment(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "arrays",
+			program: `
+			[a, b, ...rest] = [1, 2, 3, 4]
+			print(a, b, rest)
+			[first, ...others] = [1]
+			print(first, others)
+			[x, [y, z]] = ["x", ["y", "z"]]
+			print(x, y, z)`,
+			expected: "1 2 [3, 4]\n1 []\nx y z\n",
+		},
+		{
+			name: "swap",
+			program: `
+			a = 1
+			b = 2
+			[a, b] = [b, a]
+			print(a, b)
+			xs = [1, 2, 3]
+			[xs[0], xs[2]] = [xs[2], xs[0]]
+			print(xs)`,
+			expected: "2 1\n[3, 2, 1]\n",
+		},
+		{
+			name: "objects",
+			program: `
+			person = {name: "Ann", age: 30, city: "Oslo"}
+			{name, age} = person
+			print(name, age)
+			{name: n, "city": c} = person
+			print(n, c)
+			{point: {x, y}} = {point: {x: 1, y: 2}}
+			print(x + y)`,
+			expected: "Ann 30\nAnn Oslo\n3\n",
+		},
+		{
+			name: "subscript_targets",
+			program: `
+			obj = {}
+			[obj.first, ...obj.rest] = [1, 2, 3]
+			print(obj.first, obj.rest)`,
+			expected: "1 [2, 3]\n",
+		},
+		{
+			name: "for_headers",
+			program: `
+			for ([k, v] in [["a", 1], ["b", 2]]):
+				print(k, v)
+			end
+			for ({name, tags: [tag, ...rest]} in [{name: "x", tags: ["t1", "t2"]}]):
+				print(name, tag, rest)
+			end`,
+			expected: "a 1\nb 2\nx t1 [\"t2\"]\n",
+		},
+		{
+			name: "function_parameters",
+			program: `
+			fun distance([x1, y1], [x2, y2]):
+				return abs(x2 - x1) + abs(y2 - y1)
+			end
+			greet = fun({name}, greeting):
+				return greeting + ", " + name
+			end
+			print(distance([1, 2], [4, 6]), greet({name: "Bo"}, "Hi"))`,
+			expected: "7 Hi, Bo\n",
+		},
+		{
+			name: "locals_in_functions",
+			program: `
+			a = "global"
+			fun f():
+				[a, b] = [1, 2]
+				return a + b
+			end
+			print(f(), a)`,
+			expected: "3 global\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestDestructuringMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"too_few_values", "[a, b] = [1]", "value error at 1:1: expected 2 values to destructure, got 1"},
+		{"too_many_values", "[a] = [1, 2]", "value error at 1:1: expected 1 values to destructure, got 2"},
+		{"too_few_for_rest", "[a, b, ...c] = [1]", "value error at 1:1: expected at least 2 values to destructure, got 1"},
+		{"nested", "[a, [b, c]] = [1, [2]]", "value error at 1:5: expected 2 values to destructure, got 1"},
+		{"not_an_array", "[a] = 5", "value error at 1:1: can't destructure integer as an array"},
+		{"not_an_object", "{a} = [1]", "value error at 1:1: can't destructure array as an object"},
+		{"missing_key", "{a, b} = {a: 1}", `value error at 1:1: key "b" not found in object to destructure`},
+		{"for_header", "for ([k, v] in [[1]]):\nend", "value error at 1:6: expected 2 values to destructure, got 1"},
+		{"parameter", "fun f([a, b]):\nend\nf([1, 2, 3])", "value error at 1:7: expected 2 values to destructure, got 3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, test.program, test.message)
+		})
+	}
+}
+
+func TestDestructuringParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		errorMsg string
+	}{
+		{"[a, b] += [1, 2]", "parse error at 1:8: destructuring assignment only supports '=', got +="},
+		{"[a, 1] = [1, 2]", "parse error at 1:8: invalid assignment target"},
+		{"for ([a, 1] in x): end", "parse error at 1:10: invalid destructuring target"},
+		{"fun f([...a, b]): end", "parse error at 1:14: rest target '...' must be the last element of an array pattern"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseProgram([]byte(test.input))
+		if err == nil {
+			t.Errorf("Expected error parsing %q", test.input)
+		} else if !strings.Contains(err.Error(), test.errorMsg) {
+			t.Errorf("Expected error containing %q parsing %q, got %q", test.errorMsg, test.input, err.Error())
+		}
+	}
+
+	// A bracket on a new line starts a statement instead of a subscript
+	prog, err := ParseProgram([]byte("x = y\n[a, b] = [1, 2]\nz = xs[0]"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(prog.Statements) != 3 {
+		t.Fatalf("Expected 3 statements, got %d", len(prog.Statements))
+	}
+	if _, ok := prog.Statements[1].(*Assign).Target.(*ArrayTarget); !ok {
+		t.Errorf("Expected array destructuring target, got %s", prog.Statements[1])
+	}
+}
@@ -20,6 +20,8 @@ type Environment struct {
 	exit func(int)
 	// inUnitTest indicates if we're running in unit test mode
 	inUnitTest bool
+	// limits holds the resource limits of the functions it calls
+	limits limits
 }
 
 // NewEnvironment creates a new execution environment
@@ -31,6 +33,7 @@ func NewEnvironment(config *Config) *Environment {
 		stdout:     config.Stdout,
 		exit:       config.Exit,
 		inUnitTest: config.IsUnitTest,
+		limits:     newLimits(config),
 	}
 
 	// Set default I/O if not provided
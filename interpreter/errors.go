@@ -12,17 +12,26 @@ type ErrorInterpreter interface {
 	Position() Position
 }
 
+// tracedError is implemented by the runtime errors that carry the traceback
+// of the calls in progress when they were raised.
+type tracedError interface {
+	ErrorInterpreter
+	Stack() []StackFrame
+	withStack(stack []StackFrame) error
+}
+
 // TypeError is returned for invalid types and wrong number of arguments.
 // This error occurs when operations are performed on incompatible types
 // or when functions are called with incorrect argument types or counts.
 type TypeError struct {
 	Message string
 	pos     Position
+	stack   []StackFrame
 }
 
 // Error returns the formatted error message including position information.
 func (e TypeError) Error() string {
-	return fmt.Sprintf("type error at %d:%d: %s", e.pos.Line, e.pos.Column, e.Message)
+	return fmt.Sprintf("type error at %s: %s", e.pos, e.Message)
 }
 
 // Position returns the position (line and column) where the error occurred in the source.
@@ -30,10 +39,20 @@ func (e TypeError) Position() Position {
 	return e.pos
 }
 
+// Stack returns the calls in progress when the error was raised, outermost first.
+func (e TypeError) Stack() []StackFrame {
+	return e.stack
+}
+
+func (e TypeError) withStack(stack []StackFrame) error {
+	e.stack = stack
+	return e
+}
+
 // typeError creates a new TypeError with the given position and formatted message.
 // This is a helper function used internally to create type errors.
 func typeError(pos Position, format string, args ...any) error {
-	return TypeError{fmt.Sprintf(format, args...), pos}
+	return TypeError{Message: fmt.Sprintf(format, args...), pos: pos}
 }
 
 // ValueError is returned for invalid values (out of bounds index, etc).
@@ -42,11 +61,12 @@ func typeError(pos Position, format string, args ...any) error {
 type ValueError struct {
 	Message string
 	pos     Position
+	stack   []StackFrame
 }
 
 // Error returns the formatted error message including position information.
 func (e ValueError) Error() string {
-	return fmt.Sprintf("value error at %d:%d: %s", e.pos.Line, e.pos.Column, e.Message)
+	return fmt.Sprintf("value error at %s: %s", e.pos, e.Message)
 }
 
 // Position returns the position (line and column) where the error occurred in the source.
@@ -54,10 +74,20 @@ func (e ValueError) Position() Position {
 	return e.pos
 }
 
+// Stack returns the calls in progress when the error was raised, outermost first.
+func (e ValueError) Stack() []StackFrame {
+	return e.stack
+}
+
+func (e ValueError) withStack(stack []StackFrame) error {
+	e.stack = stack
+	return e
+}
+
 // valueError creates a new ValueError with the given position and formatted message.
 // This is a helper function used internally to create value errors.
 func valueError(pos Position, format string, args ...any) error {
-	return ValueError{fmt.Sprintf(format, args...), pos}
+	return ValueError{Message: fmt.Sprintf(format, args...), pos: pos}
 }
 
 // NameError is returned when a variable or function name is not found in the current scope.
@@ -65,11 +95,12 @@ func valueError(pos Position, format string, args ...any) error {
 type NameError struct {
 	Message string
 	pos     Position
+	stack   []StackFrame
 }
 
 // Error returns the formatted error message including position information.
 func (e NameError) Error() string {
-	return fmt.Sprintf("name error at %d:%d: %s", e.pos.Line, e.pos.Column, e.Message)
+	return fmt.Sprintf("name error at %s: %s", e.pos, e.Message)
 }
 
 // Position returns the position (line and column) where the error occurred in the source.
@@ -77,10 +108,20 @@ func (e NameError) Position() Position {
 	return e.pos
 }
 
+// Stack returns the calls in progress when the error was raised, outermost first.
+func (e NameError) Stack() []StackFrame {
+	return e.stack
+}
+
+func (e NameError) withStack(stack []StackFrame) error {
+	e.stack = stack
+	return e
+}
+
 // nameError creates a new NameError with the given position and formatted message.
 // This is a helper function used internally to create name errors.
 func nameError(pos Position, format string, args ...any) error {
-	return NameError{fmt.Sprintf(format, args...), pos}
+	return NameError{Message: fmt.Sprintf(format, args...), pos: pos}
 }
 
 // RuntimeError is returned for other or internal runtime errors that don't fit into
@@ -88,11 +129,12 @@ func nameError(pos Position, format string, args ...any) error {
 type RuntimeError struct {
 	Message string
 	pos     Position
+	stack   []StackFrame
 }
 
 // Error returns the formatted error message including position information.
 func (e RuntimeError) Error() string {
-	return fmt.Sprintf("runtime error at %d:%d: %s", e.pos.Line, e.pos.Column, e.Message)
+	return fmt.Sprintf("runtime error at %s: %s", e.pos, e.Message)
 }
 
 // Position returns the position (line and column) where the error occurred in the source.
@@ -100,10 +142,201 @@ func (e RuntimeError) Position() Position {
 	return e.pos
 }
 
+// Stack returns the calls in progress when the error was raised, outermost first.
+func (e RuntimeError) Stack() []StackFrame {
+	return e.stack
+}
+
+func (e RuntimeError) withStack(stack []StackFrame) error {
+	e.stack = stack
+	return e
+}
+
 // runtimeError creates a new RuntimeError with the given position and formatted message.
 // This is a helper function used internally to create runtime errors.
 func runtimeError(pos Position, format string, args ...any) error {
-	return RuntimeError{fmt.Sprintf(format, args...), pos}
+	return RuntimeError{Message: fmt.Sprintf(format, args...), pos: pos}
+}
+
+// LimitError is returned when execution is stopped because it exceeded one of
+// the resource limits in Config, or because its context was cancelled.
+// Unlike the other errors, it can't be caught by a try/catch statement.
+type LimitError struct {
+	Message string
+	pos     Position
+	cause   error
+	stack   []StackFrame
+}
+
+// Error returns the formatted error message including position information.
+func (e LimitError) Error() string {
+	return fmt.Sprintf("limit error at %s: %s", e.pos, e.Message)
+}
+
+// Position returns the position (line and column) where execution was stopped.
+func (e LimitError) Position() Position {
+	return e.pos
+}
+
+// Stack returns the calls in progress when the error was raised, outermost first.
+func (e LimitError) Stack() []StackFrame {
+	return e.stack
+}
+
+func (e LimitError) withStack(stack []StackFrame) error {
+	e.stack = stack
+	return e
+}
+
+// Unwrap returns the context error that stopped execution, if any.
+func (e LimitError) Unwrap() error {
+	return e.cause
+}
+
+// limitError creates a new LimitError with the given position, cause and formatted message.
+// This is a helper function used internally to create limit errors.
+func limitError(pos Position, cause error, format string, args ...any) error {
+	return LimitError{Message: fmt.Sprintf(format, args...), pos: pos, cause: cause}
+}
+
+// errorObject is the value assigned to the variable of a catch block. It is a
+// read-only object with the type, message, position and traceback of the
+// caught error. Converted to a string, it gives the error's message with its
+// position, the way caught errors were shown before they became objects.
+type errorObject struct {
+	fields *Object
+	text   string
+	// raised is the caught value, raised again when the object is thrown
+	raised any
+}
+
+// newErrorObject converts a value recovered from a panic into an errorObject.
+// The fields of a thrown object are kept, along with the thrown value itself.
+func newErrorObject(r any) *errorObject {
+	kind := "RuntimeError"
+	var message string
+	var pos Position
+	var stack []StackFrame
+	fields := NewObject()
+	switch e := r.(type) {
+	case UserError:
+		kind, message, pos, stack = e.Type, e.Message, e.pos, e.stack
+		if obj, ok := e.Value.(*Object); ok {
+			for _, k := range obj.keys {
+				fields.Set(k, obj.values[k])
+			}
+		}
+		fields.Set("value", e.Value)
+	case TypeError:
+		kind, message, pos, stack = "TypeError", e.Message, e.pos, e.stack
+	case ValueError:
+		kind, message, pos, stack = "ValueError", e.Message, e.pos, e.stack
+	case NameError:
+		kind, message, pos, stack = "NameError", e.Message, e.pos, e.stack
+	case RuntimeError:
+		message, pos, stack = e.Message, e.pos, e.stack
+	case Error:
+		message, pos = e.Message, e.Position
+	case ErrorInterpreter:
+		message, pos = e.Error(), e.Position()
+	case error:
+		message = e.Error()
+	default:
+		message = fmt.Sprintf("%v", r)
+	}
+	text := message
+	if err, ok := r.(error); ok {
+		text = err.Error()
+	}
+
+	frames := make([]Value, len(stack))
+	for i, frame := range stack {
+		obj := NewObject()
+		obj.Set("function", frame.Function)
+		obj.Set("line", frame.Position.Line)
+		obj.Set("column", frame.Position.Column)
+		obj.Set("file", frame.Position.File)
+		frames[i] = obj
+	}
+	fields.Set("type", kind)
+	fields.Set("message", message)
+	fields.Set("line", pos.Line)
+	fields.Set("column", pos.Column)
+	fields.Set("file", pos.File)
+	fields.Set("stack", &frames)
+	return &errorObject{fields, text, r}
+}
+
+// errorText returns the text of v if it is a caught error used with the
+// string other, and v itself otherwise. Caught errors used to be strings, so
+// they still stand for their text when added to or compared with a string.
+func errorText(v, other Value) Value {
+	if e, ok := v.(*errorObject); ok {
+		if _, ok := other.(string); ok {
+			return Value(e.text)
+		}
+	}
+	return v
+}
+
+// stringArg returns the value of a string argument of a builtin function. A
+// caught error stands for its text, as it does in string operations.
+func stringArg(v Value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case *errorObject:
+		return v.text, true
+	}
+	return "", false
+}
+
+// UserError is returned when a value raised by a throw statement isn't caught.
+// Thrown objects can set the Type and Message of the error with their "type"
+// and "message" fields; other values give their string form as the message.
+type UserError struct {
+	Type    string
+	Message string
+	Value   Value
+	pos     Position
+	stack   []StackFrame
+}
+
+// Error returns the formatted error message including position information.
+func (e UserError) Error() string {
+	if e.Type != "UserError" {
+		return fmt.Sprintf("user error at %s: %s: %s", e.pos, e.Type, e.Message)
+	}
+	return fmt.Sprintf("user error at %s: %s", e.pos, e.Message)
+}
+
+// Position returns the position (line and column) of the throw statement.
+func (e UserError) Position() Position {
+	return e.pos
+}
+
+// Stack returns the calls in progress when the error was raised, outermost first.
+func (e UserError) Stack() []StackFrame {
+	return e.stack
+}
+
+func (e UserError) withStack(stack []StackFrame) error {
+	e.stack = stack
+	return e
+}
+
+// userError creates a new UserError for a value thrown at the given position.
+func userError(pos Position, value Value) error {
+	kind, message := "UserError", toString(value, false)
+	if obj, ok := value.(*Object); ok {
+		if t, ok := obj.values["type"].(string); ok {
+			kind = t
+		}
+		if m, ok := obj.values["message"].(string); ok {
+			message = m
+		}
+	}
+	return UserError{Type: kind, Message: message, Value: value, pos: pos}
 }
 
 // BreakException is used to implement break control flow in loops.
@@ -114,7 +347,7 @@ type BreakException struct {
 
 // Error returns the formatted error message including position information.
 func (e BreakException) Error() string {
-	return fmt.Sprintf("break at %d:%d", e.pos.Line, e.pos.Column)
+	return fmt.Sprintf("break at %s", e.pos)
 }
 
 // Position returns the position (line and column) where the break occurred.
@@ -130,7 +363,7 @@ type ContinueException struct {
 
 // Error returns the formatted error message including position information.
 func (e ContinueException) Error() string {
-	return fmt.Sprintf("continue at %d:%d", e.pos.Line, e.pos.Column)
+	return fmt.Sprintf("continue at %s", e.pos)
 }
 
 // Position returns the position (line and column) where the continue occurred.
@@ -2,6 +2,8 @@ package interpreter
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -166,3 +168,194 @@ func TestErrorsInExecution(t *testing.T) {
 		// We don't check the error type, just that execution happens and errors are created
 	}
 }
+
+// runCatching runs a program on both execution engines and returns the
+// output of each
+func runCatching(t *testing.T, program string) map[Engine]string {
+	t.Helper()
+	outputs := make(map[Engine]string)
+	for _, engine := range []Engine{EngineVM, EngineTreeWalker} {
+		prog, err := ParseProgram([]byte(program))
+		if err != nil {
+			t.Fatalf("Failed to parse program: %v", err)
+		}
+		var output bytes.Buffer
+		_, err = Execute(prog, &Config{Stdout: &output, Engine: engine, MaxRecursionDepth: 10})
+		if err != nil {
+			t.Fatalf("Failed to execute program on engine %d: %v", engine, err)
+		}
+		outputs[engine] = output.String()
+	}
+	return outputs
+}
+
+// TestCaughtErrorObjects tests the fields of the error objects assigned to
+// catch variables, for each kind of error
+func TestCaughtErrorObjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		message string
+		column  int
+	}{
+		{"TypeError", `x = 1 + "a"`, "+ requires two integers, strings, arrays, or objects", 11},
+		{"ValueError", `x = [1, 2][5]`, "subscript 5 out of range", 16},
+		{"NameError", `x = missing`, `name "missing" not found`, 9},
+		{"RuntimeError", `x = fail()`, "maximum recursion depth exceeded (fail (x11))", 12},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program := `fun fail():
+    return fail()
+end
+try:
+    ` + test.code + `
+catch (e):
+    print(e.type)
+    print(e["message"])
+    print(e.line, e.column, e.file == "")
+    print(len(e.stack) > 0, typeof(e), "message" in e)
+end`
+			line := 5
+			if test.name == "RuntimeError" {
+				// Raised by the recursive call inside fail()
+				line = 2
+			}
+			expected := fmt.Sprintf("%s\n%s\n%d %d true\ntrue object true\n", test.name, test.message, line, test.column)
+			for engine, output := range runCatching(t, program) {
+				if output != expected {
+					t.Errorf("Expected %q on engine %d, got %q", expected, engine, output)
+				}
+			}
+		})
+	}
+}
+
+// TestCaughtErrorStringCompatibility tests that caught errors convert to
+// the same strings they were before they became objects, and stand for them
+// in string operations
+func TestCaughtErrorStringCompatibility(t *testing.T) {
+	program := `
+try:
+    x = 10 / 0
+catch (e):
+    print(e)
+    print("Caught: " + str(e))
+    print(e.stack)
+    print("Caught: " + e, e + "!")
+    print(e == "value error at 3:12: can't divide by zero", e != "other", "a" < e, e == e)
+    print(contains(e, "zero"), find(e, "zero"), split(e, ": ")[1], upper(e)[:5])
+end`
+	expected := "value error at 3:12: can't divide by zero\n" +
+		"Caught: value error at 3:12: can't divide by zero\n" +
+		"[{\"function\": \"<module>\", \"line\": 3, \"column\": 12, \"file\": \"\"}]\n" +
+		"Caught: value error at 3:12: can't divide by zero value error at 3:12: can't divide by zero!\n" +
+		"true true true true\n" +
+		"true 37 can't divide by zero VALUE\n"
+	for engine, output := range runCatching(t, program) {
+		if output != expected {
+			t.Errorf("Expected %q on engine %d, got %q", expected, engine, output)
+		}
+	}
+}
+
+// TestThrow tests catching values raised by throw statements
+func TestThrow(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "string",
+			program: `try:
+    throw "out of stock"
+catch (e):
+    print(e.type, e.message, e.line, e.value)
+    print(e)
+end`,
+			expected: "UserError out of stock 2 out of stock\nuser error at 2:5: out of stock\n",
+		},
+		{
+			name: "object_with_custom_type",
+			program: `fun check(qty):
+    if (qty < 0) then:
+        throw {"type": "ValidationError", "message": "negative quantity", "field": "qty"}
+    end
+    return qty
+end
+try:
+    check(-1)
+catch (e):
+    print(e.type, e.message, e.field, len(e.stack))
+    print(str(e))
+end`,
+			expected: "ValidationError negative quantity qty 2\nuser error at 3:9: ValidationError: negative quantity\n",
+		},
+		{
+			name: "rethrow_keeps_original_error",
+			program: `try:
+    try:
+        x = [1][3]
+    catch (inner):
+        throw inner
+    end
+catch (outer):
+    print(outer.type, outer.line, outer.column)
+end`,
+			expected: "ValueError 3 17\n",
+		},
+		{
+			name: "throw_in_function_{}_block",
+			program: `fun fail(n) {
+    throw n * 2
+}
+try {
+    fail(21)
+} catch (e) {
+    print(e.value + 1)
+}`,
+			expected: "43\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for engine, output := range runCatching(t, test.program) {
+				if output != test.expected {
+					t.Errorf("Expected %q on engine %d, got %q", test.expected, engine, output)
+				}
+			}
+		})
+	}
+}
+
+// TestUncaughtThrow tests that uncaught thrown values surface as UserError
+func TestUncaughtThrow(t *testing.T) {
+	prog, err := ParseProgram([]byte("fun main():\n    throw {\"type\": \"ConfigError\", \"message\": \"missing key\"}\nend"))
+	if err != nil {
+		t.Fatalf("Failed to parse program: %v", err)
+	}
+	for _, engine := range []Engine{EngineVM, EngineTreeWalker} {
+		_, err := Execute(prog, &Config{Stdout: &bytes.Buffer{}, Engine: engine})
+		userErr, ok := err.(UserError)
+		if !ok {
+			t.Fatalf("Expected UserError on engine %d, got %v", engine, err)
+		}
+		if userErr.Type != "ConfigError" || userErr.Message != "missing key" {
+			t.Errorf("Unexpected UserError fields on engine %d: %+v", engine, userErr)
+		}
+		if _, ok := userErr.Value.(*Object); !ok {
+			t.Errorf("Expected thrown object as Value on engine %d, got %v", engine, userErr.Value)
+		}
+		if pos := userErr.Position(); pos.Line != 2 || pos.Column != 5 {
+			t.Errorf("Expected error at 2:5 on engine %d, got %v", engine, pos)
+		}
+	}
+
+	success, output := RunProgram("throw \"boom\"")
+	if success || !strings.Contains(output, "user error at 1:1: boom") {
+		t.Errorf("Expected uncaught throw to fail with its message, got: %s", output)
+	}
+}
@@ -50,11 +50,13 @@ func (e *Evaluator) EvaluateExpression(expr Expression) Value {
 		return e.evaluateSubscript(node)
 
 	case *FunctionExpression:
+		resolveExpression(node)
 		return &userFunction{
 			Name:       "",
 			Parameters: node.Parameters,
 			Body:       node.Body,
-			Closure:    e.env.vars[len(e.env.vars)-1], // Capture current scope
+			prologue:   node.prologue,
+			scope:      node.scope,
 		}
 
 	default:
@@ -167,13 +169,14 @@ func (e *Evaluator) evaluateCall(node *Call) Value {
 	case builtinFunction:
 		// Create a temporary interpreter for builtin calls
 		interp := &interpreter{
-			vars:       e.env.vars,
+			globals:    e.env.vars[0],
 			args:       e.env.args,
 			stdin:      e.env.stdin,
 			stdout:     e.env.stdout,
 			exit:       e.env.exit,
 			stats:      *e.stats,
 			inUnitTest: e.env.inUnitTest,
+			limits:     e.env.limits,
 		}
 		result := fn.call(interp, node.Position(), args)
 		*e.stats = interp.stats // Update stats
@@ -194,7 +197,7 @@ func (e *Evaluator) evaluateList(node *List) Value {
 
 // evaluateMap evaluates map literals
 func (e *Evaluator) evaluateMap(node *Map) Value {
-	result := make(map[string]Value)
+	result := NewObject()
 	for _, item := range node.Items {
 		key := e.EvaluateExpression(item.Key)
 		value := e.EvaluateExpression(item.Value)
@@ -204,7 +207,7 @@ func (e *Evaluator) evaluateMap(node *Map) Value {
 			panic(typeError(item.Key.Position(), "map key must be string, got %T", key))
 		}
 
-		result[keyStr] = value
+		result.Set(keyStr, value)
 	}
 	return result
 }
@@ -231,12 +234,12 @@ func (e *Evaluator) evaluateSubscript(node *Subscript) Value {
 		}
 		return (*c)[idx]
 
-	case map[string]Value:
+	case *Object:
 		key, ok := index.(string)
 		if !ok {
 			panic(typeError(node.Position(), "map key must be string, got %T", index))
 		}
-		if value, exists := c[key]; exists {
+		if value, exists := c.Get(key); exists {
 			return value
 		}
 		return nil
@@ -264,59 +267,24 @@ func (e *Evaluator) evaluateSubscript(node *Subscript) Value {
 
 // callUserFunction calls a user-defined function
 func (e *Evaluator) callUserFunction(fn *userFunction, pos Position, args []Value) Value {
-	e.stats.UserCalls++
-
 	// Check parameter count
 	if len(args) != len(fn.Parameters) {
 		panic(valueError(pos, "function %s expects %d arguments, got %d",
 			fn.Name, len(fn.Parameters), len(args)))
 	}
 
-	// Save current scope stack
-	oldVars := e.env.vars
-
-	// Set up function's closure as the base scope
-	if fn.Closure != nil {
-		// Create a new scope with the closure
-		e.env.vars = []map[string]Value{fn.Closure}
-	} else {
-		// Create empty scope
-		e.env.vars = []map[string]Value{make(map[string]Value)}
-	}
-
-	// Create new scope for function parameters
-	e.env.PushScope()
-	for i, param := range fn.Parameters {
-		e.env.Assign(param, args[i])
-	}
-
-	// Execute function body
-	var result Value
-	defer func() {
-		// Restore original scope stack
-		e.env.vars = oldVars
-
-		// Handle return values
-		if r := recover(); r != nil {
-			if ret, ok := r.(returnResult); ok {
-				result = ret.value
-			} else {
-				panic(r) // Re-panic other errors
-			}
-		}
-	}()
-
-	// Execute function body
+	// Execute function body with the global scope of this environment
 	interp := &interpreter{
-		vars:       e.env.vars,
+		globals:    e.env.vars[0],
 		args:       e.env.args,
 		stdin:      e.env.stdin,
 		stdout:     e.env.stdout,
 		exit:       e.env.exit,
 		stats:      *e.stats,
 		inUnitTest: e.env.inUnitTest,
+		limits:     e.env.limits,
 	}
-	interp.executeBlock(fn.Body)
+	result := fn.call(interp, pos, args)
 	*e.stats = interp.stats // Update stats
 
 	return result // Will be nil if no explicit return
@@ -0,0 +1,30 @@
+package interpreter
+
+import "testing"
+
+func TestEvaluatorCallsUserFunctions(t *testing.T) {
+	evaluator := NewEvaluator(NewEnvironment(&Config{}), &Stats{})
+	evaluate := func(source string) Value {
+		t.Helper()
+		expr, err := ParseExpression([]byte(source))
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", source, err)
+		}
+		return evaluator.EvaluateExpression(expr)
+	}
+
+	evaluator.env.Assign("double", evaluate("fun(x): return x * 2 end"))
+	tests := []struct {
+		source   string
+		expected Value
+	}{
+		{"double(21)", 42},
+		{"(fun(a, b): return a + b end)(1, 2)", 3},
+		{"len(str(double(50)))", 3},
+	}
+	for _, test := range tests {
+		if result := evaluate(test.source); result != test.expected {
+			t.Errorf("Expected %v for %q, got %v", test.expected, test.source, result)
+		}
+	}
+}
@@ -25,11 +25,15 @@ type functionType interface {
 
 // userFunction represents a function defined by the user in the script
 type userFunction struct {
-	Name       string           // Function name (can be empty for anonymous functions)
-	Parameters []string         // Parameter names
-	Ellipsis   bool             // Whether the last parameter is variadic
-	Body       Block            // Function body statements
-	Closure    map[string]Value // Captured variables from outer scopes
+	Name       string       // Function name (can be empty for anonymous functions)
+	Parameters []string     // Parameter names
+	Defaults   []Expression // Default values of the parameters, nil if none has one
+	Ellipsis   bool         // Whether the last parameter is variadic
+	Body       Block        // Function body statements
+	prologue   Block        // Assigns default values and destructures parameters before the body
+	Closure    *environment // Environment the function was created in
+	scope      *scope       // Local variables of the function
+	code       *codeObject  // Compiled body when running on the VM
 }
 
 // ensureNumArgs checks if the number of arguments matches the required count
@@ -41,12 +45,137 @@ type userFunction struct {
 //   - required: Required number of arguments
 func ensureNumArgs(pos Position, name string, args []Value, required int) {
 	if len(args) != required {
-		plural := ""
-		if required != 1 {
-			plural = "s"
+		panic(typeError(pos, "%s() requires %d arg%s, got %d", name, required, plural(required), len(args)))
+	}
+}
+
+// bindArgs packs variadic arguments and verifies the argument count,
+// returning one value per parameter. Parameters with a default value may be
+// left without an argument: their slot stays unbound until the prologue of
+// the function assigns the default value.
+func (f *userFunction) bindArgs(pos Position, args []Value) []Value {
+	fixed := len(f.Parameters)
+	if f.Ellipsis {
+		fixed--
+	}
+	if len(args) > fixed && !f.Ellipsis {
+		panic(typeError(pos, "%s() requires %s, got %d", f.displayName(), f.arity(), countArgs(args)))
+	}
+	bound := args
+	if f.Ellipsis || len(args) < fixed {
+		bound = make([]Value, len(f.Parameters))
+		copy(bound, args[:min(len(args), fixed)])
+		for i := len(args); i < fixed; i++ {
+			bound[i] = unboundValue{}
+		}
+		// Handle variadic arguments if this is a variadic function
+		if f.Ellipsis {
+			ellipsisArgs := []Value{}
+			if len(args) > fixed {
+				ellipsisArgs = append(ellipsisArgs, args[fixed:]...)
+			}
+			bound[fixed] = Value(&ellipsisArgs)
+		}
+	}
+
+	// Verify that every parameter without a default value got an argument
+	for i := 0; i < fixed; i++ {
+		if bound[i] == (unboundValue{}) && !f.hasDefault(i) {
+			panic(typeError(pos, "%s() requires %s, got %d: missing argument for parameter %q",
+				f.displayName(), f.arity(), countArgs(args), f.Parameters[i]))
+		}
+	}
+	return bound
+}
+
+// displayName returns the name of the function for errors, which is <fun>
+// for anonymous functions, like in tracebacks.
+func (f *userFunction) displayName() string {
+	if f.Name == "" {
+		return "<fun>"
+	}
+	return f.Name
+}
+
+// hasDefault reports whether parameter i has a default value.
+func (f *userFunction) hasDefault(i int) bool {
+	return f.Defaults != nil && f.Defaults[i] != nil
+}
+
+// arity describes the number of arguments the function takes, for errors.
+func (f *userFunction) arity() string {
+	fixed := len(f.Parameters)
+	if f.Ellipsis {
+		fixed--
+	}
+	required := 0
+	for i := 0; i < fixed; i++ {
+		if !f.hasDefault(i) {
+			required++
+		}
+	}
+	switch {
+	case f.Ellipsis:
+		return fmt.Sprintf("at least %d arg%s", required, plural(required))
+	case required < fixed:
+		return fmt.Sprintf("%d to %d args", required, fixed)
+	}
+	return fmt.Sprintf("%d arg%s", fixed, plural(fixed))
+}
+
+// plural returns the suffix of a plural noun for a count of n.
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// countArgs returns the number of arguments given to a call, not counting
+// the parameters placeNamedArgs left unbound.
+func countArgs(args []Value) int {
+	n := 0
+	for _, arg := range args {
+		if arg != (unboundValue{}) {
+			n++
+		}
+	}
+	return n
+}
+
+// placeNamedArgs returns the arguments of a call with named arguments as
+// positional ones: the value of each named argument goes to the position of
+// the parameter of that name, after the positional arguments args. Only user
+// functions take named arguments. Parameters that get no argument are left
+// unbound, for bindArgs to check.
+func placeNamedArgs(pos Position, f functionType, args []Value, names []string, values []Value) []Value {
+	uf, ok := f.(*userFunction)
+	if !ok {
+		panic(typeError(pos, "%s doesn't accept named arguments", f.name()))
+	}
+	for i, name := range names {
+		// A repeated parameter name refers to the last parameter
+		index := -1
+		for j, param := range uf.Parameters {
+			if param == name {
+				index = j
+			}
+		}
+		switch {
+		case index < 0:
+			panic(typeError(pos, "%s() has no parameter named %q", uf.displayName(), name))
+		case uf.Ellipsis && index == len(uf.Parameters)-1:
+			panic(typeError(pos, "%s() can't take variadic parameter %q by name", uf.displayName(), name))
+		}
+		for len(args) <= index {
+			args = append(args, unboundValue{})
 		}
-		panic(typeError(pos, "%s() requires %d arg%s, got %d", name, required, plural, len(args)))
+		if args[index] != (unboundValue{}) {
+			panic(typeError(pos, "%s() got multiple values for parameter %q", uf.displayName(), name))
+		}
+		args[index] = values[i]
 	}
+	return args
 }
 
 // call implements the functionType interface for user-defined functions
@@ -58,35 +187,38 @@ func ensureNumArgs(pos Position, name string, args []Value, required int) {
 //
 // Returns the function's return value or nil if no return statement was executed
 func (f *userFunction) call(interp *interpreter, pos Position, args []Value) Value {
-	// Handle variadic arguments if this is a variadic function
-	if f.Ellipsis {
-		ellipsisArgs := args[len(f.Parameters)-1:]
-		newArgs := make([]Value, 0, len(f.Parameters)+1)
-		newArgs = append(newArgs, args[:len(f.Parameters)-1]...)
-		args = append(newArgs, Value(&ellipsisArgs))
+	// Calling a generator function only creates its iterator
+	if f.scope != nil && f.scope.generator {
+		return newGenerator(interp, f, pos, args)
 	}
 
-	// Verify argument count
-	ensureNumArgs(pos, f.Name, args, len(f.Parameters))
+	// Compiled functions run on the VM
+	if f.code != nil && interp.vm != nil {
+		return interp.vm.call(f, pos, args)
+	}
 
-	// Set up closure scope (captured variables)
-	interp.pushScope(f.Closure)
-	defer interp.popScope()
+	args = f.bindArgs(pos, args)
+	interp.enterCall(f, pos)
 
-	// Set up local function scope
-	interp.pushScope(make(map[string]Value))
-	defer interp.popScope()
-
-	// Assign arguments to parameters
-	for i, arg := range args {
-		interp.assign(f.Parameters[i], arg)
-	}
+	// Set up the local environment, with the arguments in the parameter slots
+	env := newEnvironment(f.scope, f.Closure)
+	copy(env.slots, args)
+	caller := interp.env
+	interp.env = env
 
 	// Track function call statistics
 	interp.stats.UserCalls++
 
-	// Execute the function body
-	interp.executeBlock(f.Body)
+	// Execute the function body, after binding the parameters
+	interp.executeBlock(f.prologue)
+	result := interp.executeBlock(f.Body)
+	interp.env = caller
+	interp.exitCall()
+	if result.kind == completionReturn {
+		return result.value
+	}
+	// A break or continue outside of a loop can't escape the function
+	raiseStray(result)
 	return Value(nil)
 }
 
@@ -315,6 +447,9 @@ func findFunc(interp *interpreter, pos Position, args []Value) Value {
 			return Value(strings.Index(haystack, needle))
 		}
 		panic(typeError(pos, "find() on string requires second argument to be a string"))
+	case *errorObject:
+		// A caught error is searched as its text
+		return findFunc(interp, pos, []Value{haystack.text, args[1]})
 	case *[]Value:
 		needle := args[1]
 		for i, v := range *haystack {
@@ -450,9 +585,12 @@ func lenFunc(interp *interpreter, pos Position, args []Value) Value {
 	case *[]Value:
 		// Number of elements in array (pointer variant)
 		length = len(*arg)
-	case map[string]Value:
+	case *Object:
 		// Number of key-value pairs in object
-		length = len(arg)
+		length = arg.Len()
+	case *errorObject:
+		// Number of fields of a caught error
+		length = arg.fields.Len()
 	default:
 		panic(typeError(pos, "len() requires a string, array, or object"))
 	}
@@ -468,7 +606,7 @@ func lenFunc(interp *interpreter, pos Position, args []Value) Value {
 // Example: lower("HELLO") -> "hello"
 func lowerFunc(interp *interpreter, pos Position, args []Value) Value {
 	ensureNumArgs(pos, "lower", args, 1)
-	if s, ok := args[0].(string); ok {
+	if s, ok := stringArg(args[0]); ok {
 		return Value(strings.ToLower(s))
 	}
 	panic(typeError(pos, "lower() requires a string"))
@@ -686,7 +824,7 @@ func splitFunc(interp *interpreter, pos Position, args []Value) Value {
 	}
 
 	// Check that first argument is a string
-	str, ok := args[0].(string)
+	str, ok := stringArg(args[0])
 	if !ok {
 		panic(typeError(pos, "split() requires first argument to be a string"))
 	}
@@ -721,7 +859,7 @@ func isregexFunc(interp *interpreter, pos Position, args []Value) Value {
 	// Check that first argument is a string (pattern)
 	if pattern, ok := args[0].(string); ok {
 		// Check that second argument is a string (target)
-		if str, ok := args[1].(string); ok {
+		if str, ok := stringArg(args[1]); ok {
 			// Compile the regular expression
 			re, err := regexp.Compile(pattern)
 			if err != nil {
@@ -800,6 +938,9 @@ func containsFunc(interp *interpreter, pos Position, args []Value) Value {
 			return Value(strings.Contains(haystack, needle))
 		}
 		panic(typeError(pos, "contains() on str requires second argument to be a string"))
+	case *errorObject:
+		// A caught error is searched as its text
+		return containsFunc(interp, pos, []Value{haystack.text, args[1]})
 	case *[]Value:
 		needle := args[1]
 		for _, v := range *haystack {
@@ -817,7 +958,7 @@ func containsFunc(interp *interpreter, pos Position, args []Value) Value {
 // Example: str_pad("hello", 10, " ") -> "hello     "
 func strpadFunc(interp *interpreter, pos Position, args []Value) Value {
 	ensureNumArgs(pos, "str_pad", args, 3)
-	if s, ok := args[0].(string); ok {
+	if s, ok := stringArg(args[0]); ok {
 		if padLen, ok := args[1].(int); ok {
 			if padStr, ok := args[2].(string); ok {
 				return Value(s + strings.Repeat(padStr, padLen))
@@ -833,7 +974,7 @@ func strpadFunc(interp *interpreter, pos Position, args []Value) Value {
 // Example: substr("hello", 1, 3) -> "ell"
 func substrFunc(interp *interpreter, pos Position, args []Value) Value {
 	ensureNumArgs(pos, "substr", args, 3)
-	if s, ok := args[0].(string); ok {
+	if s, ok := stringArg(args[0]); ok {
 		if start, ok := args[1].(int); ok {
 			if end, ok := args[2].(int); ok {
 				return Value(s[start:end])
@@ -887,15 +1028,15 @@ func toString(value Value, quoteStr bool) string {
 			strs[i] = toString(v, true)
 		}
 		s = fmt.Sprintf("[%s]", strings.Join(strs, ", "))
-	case map[string]Value:
-		// Convert object key-value pairs recursively
-		strs := make([]string, 0, len(v))
-		for k, v := range v {
-			item := fmt.Sprintf("%q: %s", k, toString(v, true))
-			strs = append(strs, item)
-		}
-		sort.Strings(strs) // Ensure str(output) is consistent
+	case *Object:
+		// Convert object key-value pairs recursively, in insertion order
+		strs := make([]string, len(v.keys))
+		for i, k := range v.keys {
+			strs[i] = fmt.Sprintf("%q: %s", k, toString(v.values[k], true))
+		}
 		s = fmt.Sprintf("{%s}", strings.Join(strs, ", "))
+	case *errorObject:
+		s = v.text // Error message, as caught errors used to be strings
 	case functionType:
 		s = v.name() // Function representation
 	default:
@@ -931,7 +1072,7 @@ func typeName(v Value) string {
 		t = "string" // String value
 	case *[]Value:
 		t = "array" // Array value
-	case map[string]Value:
+	case *Object, *errorObject:
 		t = "object" // Map/Object value
 	case functionType:
 		t = "function" // Function value
@@ -949,7 +1090,7 @@ func typeofFunc(interp *interpreter, pos Position, args []Value) Value {
 
 func upperFunc(interp *interpreter, pos Position, args []Value) Value {
 	ensureNumArgs(pos, "upper", args, 1)
-	if s, ok := args[0].(string); ok {
+	if s, ok := stringArg(args[0]); ok {
 		return Value(strings.ToUpper(s))
 	}
 	panic(typeError(pos, "upper() requires a string"))
@@ -1016,24 +1157,25 @@ func importFunc(interp *interpreter, pos Position, args []Value) Value {
 	}
 
 	// Parse the imported program
-	importedProg, err := ParseProgram(fileContent)
+	importedProg, err := ParseProgramFile(foundPath, fileContent)
 	if err != nil {
 		fmt.Fprintf(interp.stdout, "Error parsing imported file %s: %s\n", foundPath, err)
 		return Value(false)
 	}
+	interp.registerSource(foundPath, fileContent)
 
 	// Execute the imported program
 	// We don't want to call the main function of the imported file
 	// We just want to execute the top-level statements and define the functions
+	statements := Block{}
 	for _, statement := range importedProg.Statements {
 		// Skip if statement is a main function definition
 		if funcDef, ok := statement.(*FunctionDefinition); ok && funcDef.Name == "main" {
 			continue
 		}
-
-		// Execute the statement
-		interp.executeStatement(statement)
+		statements = append(statements, statement)
 	}
+	interp.executeTopLevel(foundPath, statements)
 
 	return Value(true)
 }
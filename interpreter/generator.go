@@ -0,0 +1,231 @@
+package interpreter
+
+import (
+	"runtime"
+)
+
+// generator is a call in progress of a generator function, a function whose
+// body contains a yield statement. Calling a generator function doesn't run
+// its body: it returns an iterator object whose next function is the
+// generator. Each call to next runs the body up to its next yield statement
+// and returns an object with the yielded value and a done field, which is
+// true once the body has returned.
+type generator struct {
+	fn *userFunction
+	// env is the environment of the body, saved while it is suspended
+	env *environment
+	// running is set while the body runs, and done once it has finished
+	running, done bool
+
+	// On the VM, the body is suspended by saving its frame: the program
+	// counter, its part of the operand stack, and its try handlers, whose
+	// frame, stack and calls fields are kept relative to the frame.
+	pc       int
+	stack    []Value
+	handlers []tryHandler
+
+	// On the tree-walker, the body runs in a goroutine of its own, which
+	// takes turns with its callers over these channels.
+	resume  chan struct{}
+	results chan generatorResult
+	// closed is set when the program finishes with the body suspended
+	closed bool
+}
+
+// generatorResult is sent by the goroutine of a generator when its body
+// yields a value, returns, or raises an error.
+type generatorResult struct {
+	value  Value
+	done   bool
+	raised any
+}
+
+// newGenerator binds the arguments of a call to a generator function and
+// returns the iterator object of the call. Default values are evaluated and
+// parameters destructured now, like in calls to other functions.
+func newGenerator(interp *interpreter, f *userFunction, pos Position, args []Value) Value {
+	args = f.bindArgs(pos, args)
+	env := newEnvironment(f.scope, f.Closure)
+	copy(env.slots, args)
+	interp.stats.UserCalls++
+
+	g := &generator{fn: f, env: env}
+	if len(f.prologue) != 0 {
+		g.bind(interp, pos)
+	}
+	iterator := NewObject()
+	iterator.Set("next", g)
+	return iterator
+}
+
+// bind runs the prologue of the generator function, which assigns the
+// default values of the parameters and destructures them.
+func (g *generator) bind(interp *interpreter, pos Position) {
+	if interp.vm != nil {
+		// The compiled body stops after the prologue the first time it runs
+		g.step(interp, pos)
+		return
+	}
+	caller := interp.env
+	interp.enterCall(g.fn, pos)
+	interp.env = g.env
+	interp.executeBlock(g.fn.prologue)
+	interp.env = caller
+	interp.exitCall()
+}
+
+// call implements the functionType interface. It resumes the body and
+// returns the next result of the iterator protocol.
+func (g *generator) call(interp *interpreter, pos Position, args []Value) Value {
+	ensureNumArgs(pos, "next", args, 0)
+	var value Value
+	if !g.done {
+		value = g.step(interp, pos)
+	}
+	result := NewObject()
+	result.Set("value", value)
+	result.Set("done", g.done)
+	return result
+}
+
+// name implements the functionType interface.
+func (g *generator) name() string {
+	if g.fn.Name == "" {
+		return "<generator>"
+	}
+	return "<generator " + g.fn.Name + ">"
+}
+
+// step runs the body until it yields a value or returns. An error raised
+// by the body finishes the generator.
+func (g *generator) step(interp *interpreter, pos Position) Value {
+	if g.running {
+		panic(runtimeError(pos, "generator is already running"))
+	}
+	g.running = true
+	defer func() {
+		g.running = false
+		if r := recover(); r != nil {
+			g.done = true
+			panic(r)
+		}
+	}()
+	if interp.vm != nil {
+		return interp.vm.resumeGenerator(g, pos)
+	}
+	return g.resumeGoroutine(interp, pos)
+}
+
+// resumeGoroutine hands control to the goroutine running the body on the
+// tree-walker, starting it on the first call, and waits for its result.
+func (g *generator) resumeGoroutine(interp *interpreter, pos Position) Value {
+	env, calls, callBase, current := interp.env, len(interp.calls), interp.callBase, interp.generator
+	interp.enterCall(g.fn, pos)
+	interp.callBase, interp.generator = len(interp.calls), g
+	if g.resume == nil {
+		g.resume = make(chan struct{})
+		g.results = make(chan generatorResult)
+		interp.generators = append(interp.generators, g)
+		go g.run(interp)
+	} else {
+		g.resume <- struct{}{}
+	}
+	result := <-g.results
+	interp.env, interp.calls, interp.callBase, interp.generator = env, interp.calls[:calls], callBase, current
+	if result.raised != nil {
+		panic(result.raised)
+	}
+	g.done = result.done
+	return result.value
+}
+
+// run is the goroutine running the body of a generator on the tree-walker.
+func (g *generator) run(interp *interpreter) {
+	var result generatorResult
+	defer func() {
+		if g.closed {
+			return
+		}
+		if r := recover(); r != nil {
+			// The traceback is taken before the caller's calls are restored
+			result = generatorResult{raised: interp.attachStack(r)}
+		}
+		g.results <- result
+	}()
+	interp.env = g.env
+	if completion := interp.executeBlock(g.fn.Body); completion.kind != completionReturn {
+		raiseStray(completion)
+	}
+	result.done = true
+}
+
+// yield hands a value yielded by the body to the caller on the tree-walker
+// and waits to be resumed.
+func (g *generator) yield(interp *interpreter, value Value) {
+	env := interp.env
+	g.results <- generatorResult{value: value}
+	if _, ok := <-g.resume; !ok {
+		// The program has finished; stop without running the rest of the body
+		runtime.Goexit()
+	}
+	interp.env = env
+}
+
+// closeGenerators stops the goroutines of the generators left suspended on
+// the tree-walker when the program finishes.
+func (interp *interpreter) closeGenerators() {
+	for _, g := range interp.generators {
+		if !g.done && !g.running {
+			g.closed = true
+			close(g.resume)
+		}
+	}
+	interp.generators = nil
+}
+
+// resumeGenerator runs the body of a generator on the VM, in a frame on top
+// of the current ones, from where it last yielded until it yields again or
+// returns.
+func (m *vm) resumeGenerator(g *generator, pos Position) Value {
+	interp := m.interp
+	depth := len(m.frames)
+	interp.enterCall(g.fn, pos)
+	base := len(m.stack)
+	m.stack = append(m.stack, g.stack...)
+	for _, h := range g.handlers {
+		h.frame += depth
+		h.stack += base
+		h.calls += len(interp.calls)
+		m.handlers = append(m.handlers, h)
+	}
+	m.frames = append(m.frames, vmFrame{code: g.fn.code, pc: g.pc, base: base, env: interp.env, fn: g.fn, gen: g})
+	interp.env = g.env
+	return m.execute(depth)
+}
+
+// suspend saves the state of the generator frame on top of the frame stack
+// when its body yields, and removes the frame.
+func (m *vm) suspend(g *generator, pc int) {
+	interp := m.interp
+	index := len(m.frames) - 1
+	frame := &m.frames[index]
+	first := len(m.handlers)
+	for first > 0 && m.handlers[first-1].frame >= index {
+		first--
+	}
+	g.handlers = g.handlers[:0]
+	for _, h := range m.handlers[first:] {
+		h.frame -= index
+		h.stack -= frame.base
+		h.calls -= len(interp.calls)
+		g.handlers = append(g.handlers, h)
+	}
+	m.handlers = m.handlers[:first]
+	g.pc, g.env = pc, interp.env
+	g.stack = append(g.stack[:0], m.stack[frame.base:]...)
+
+	interp.env = frame.env
+	interp.exitCall()
+	m.stack = m.stack[:frame.base]
+	m.frames = m.frames[:index]
+}
@@ -0,0 +1,240 @@
+package interpreter
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "infinite_sequence",
+			program: `
+			fun naturals():
+				n = 0
+				while (true):
+					yield n
+					n += 1
+				end
+			end
+			for (n in naturals()):
+				if (n == 3) then:
+					break
+				end
+				print(n)
+			end`,
+			expected: "0\n1\n2\n",
+		},
+		{
+			name: "chained",
+			program: `
+			fun naturals():
+				n = 1
+				while (true):
+					yield n
+					n += 1
+				end
+			end
+			fun take(it, count):
+				for (i, v in it):
+					if (i == count) then:
+						return null
+					end
+					yield v
+				end
+			end
+			squares = fun(it):
+				for (v in it):
+					yield v * v
+				end
+			end
+			for (v in take(squares(naturals()), 4)):
+				print(v)
+			end
+			print(max(take(naturals(), 5)...))`,
+			expected: "1\n4\n9\n16\n5\n",
+		},
+		{
+			name: "next",
+			program: `
+			fun pair(a, b):
+				yield a
+				yield b
+			end
+			g = pair("x", "y")
+			print(g.next())
+			print(g.next())
+			print(g.next())
+			print(g.next())`,
+			expected: "{\"value\": \"x\", \"done\": false}\n{\"value\": \"y\", \"done\": false}\n" +
+				"{\"value\": null, \"done\": true}\n{\"value\": null, \"done\": true}\n",
+		},
+		{
+			name: "body_runs_lazily",
+			program: `
+			fun noisy():
+				print("started")
+				yield 1
+				print("resumed")
+			end
+			g = noisy()
+			print("created")
+			for (v in g):
+				print(v)
+			end`,
+			expected: "created\nstarted\n1\nresumed\n",
+		},
+		{
+			name: "try_blocks_across_yields",
+			program: `
+			fun guarded():
+				try:
+					yield "a"
+					throw "boom"
+				catch (e):
+					yield "caught " + e.message
+				finally:
+					yield "finally"
+				end
+			end
+			fun depth(n, g):
+				if (n == 0) then:
+					return g.next().value
+				end
+				return depth(n - 1, g)
+			end
+			g = guarded()
+			print(depth(3, g), depth(0, g), depth(5, g), depth(1, g))`,
+			expected: "a caught boom finally null\n",
+		},
+		{
+			name: "errors_end_the_generator",
+			program: `
+			fun failing():
+				yield 1
+				x = 1 / 0
+				yield 2
+			end
+			g = failing()
+			print(g.next().value)
+			try:
+				g.next()
+			catch (e):
+				print(e.message, e.stack[1].function)
+			end
+			print(g.next().done)`,
+			expected: "1\ncan't divide by zero failing\ntrue\n",
+		},
+		{
+			name: "iterator_protocol",
+			program: `
+			fun countdown(from):
+				n = from
+				return {
+					next: fun():
+						nonlocal n
+						n -= 1
+						if (n < 0) then:
+							return {done: true}
+						end
+						return {value: n, done: false}
+					end,
+				}
+			end
+			for (i, v in countdown(3)):
+				print(i, v)
+			end
+			print(str(countdown(1)...))`,
+			expected: "0 2\n1 1\n2 0\n0\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"already_running", "fun g():\nyield it.next()\nend\nit = g()\nit.next()", "runtime error at 2:9: generator is already running"},
+		{"next_arguments", "fun g():\nyield 1\nend\ng().next(1)", "type error at 4:4: next() requires 0 args, got 1"},
+		{"next_result", "for (x in {next: fun(): return 1 end}):\nend", "type error at 1:11: iterator next() must return an object, got integer"},
+		{"done_field", "for (x in {next: fun(): return {} end}):\nend", "type error at 1:11: iterator next() must return an object with a bool done field"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, test.program, test.message)
+		})
+	}
+}
+
+func TestYieldOutsideFunction(t *testing.T) {
+	_, err := ParseProgram([]byte("x = 1\nyield x"))
+	if err == nil || !strings.Contains(err.Error(), "parse error at 2:1: yield outside of function") {
+		t.Errorf("Expected yield outside of function error, got %v", err)
+	}
+}
+
+func TestInfiniteGeneratorHitsOpLimit(t *testing.T) {
+	prog, err := ParseProgram([]byte(`
+	fun forever():
+		while (true):
+			yield 1
+		end
+	end
+	print(forever()...)`))
+	if err != nil {
+		t.Fatalf("Failed to parse program: %v", err)
+	}
+	for _, engine := range []Engine{EngineVM, EngineTreeWalker} {
+		_, err := Execute(prog, &Config{Engine: engine, MaxOps: 10000, IsUnitTest: true})
+		var limitErr LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("Expected LimitError on engine %d, got %v", engine, err)
+		}
+	}
+}
+
+func TestSuspendedGeneratorsAreClosed(t *testing.T) {
+	before := runtime.NumGoroutine()
+	_, _, err := runWithEngine(t, `
+	fun naturals():
+		n = 0
+		while (true):
+			try:
+				yield n
+			catch (e):
+				print("unreachable")
+			end
+			n += 1
+		end
+	end
+	for (i in range(20)):
+		g = naturals()
+		g.next()
+	end`, EngineTreeWalker)
+	if err != nil {
+		t.Fatalf("Failed to execute program: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("Expected suspended generators to be stopped, %d goroutines left over", n-before)
+	}
+}
@@ -2,7 +2,9 @@ package interpreter
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -142,3 +144,27 @@ func TestImportStatementSyntaxError(t *testing.T) {
 		t.Errorf("Expected error to mention the broken library file, got: %v", err)
 	}
 }
+
+func TestImportedFileErrorSource(t *testing.T) {
+	lib := filepath.Join(t.TempDir(), "broken_lib.din")
+	libContent := "fun lookup(items):\n    return items[10]\nend\n"
+	if err := os.WriteFile(lib, []byte(libContent), 0644); err != nil {
+		t.Fatalf("Failed to create test library file: %v", err)
+	}
+
+	success, output := RunProgram(fmt.Sprintf("import %q\nx = 1\nlookup([1, 2])", lib))
+	if success {
+		t.Fatalf("Expected execution to fail, got: %s", output)
+	}
+	if !strings.Contains(output, "value error at "+lib+":2:18:") {
+		t.Errorf("Expected error position in %s, got: %s", lib, output)
+	}
+	if !strings.Contains(output, "    return items[10]\n") {
+		t.Errorf("Expected source line from %s, got: %s", lib, output)
+	}
+
+	_, err := ParseProgramFile(lib, []byte("x = (1"))
+	if err == nil || !strings.Contains(err.Error(), "parse error at "+lib+":1:7:") {
+		t.Errorf("Expected parse error in %s, got %v", lib, err)
+	}
+}
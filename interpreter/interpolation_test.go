@@ -0,0 +1,110 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "expressions",
+			program: `
+			fun square(n): return n * n end
+			n = 4
+			print("square(${n}) = ${square(n)}")
+			print('${n}${n + 1}', "${"[" + str(n) + "]"}")`,
+			expected: "square(4) = 16\n45 [4]\n",
+		},
+		{
+			name: "values_print_like_str",
+			program: `
+			items = [1, "two", null]
+			print("items: ${items}, first: ${items[1]}, obj: ${{a: true}}")`,
+			expected: "items: [1, \"two\", null], first: two, obj: {\"a\": true}\n",
+		},
+		{
+			name: "nested",
+			program: `
+			names = ["ann", "bob"]
+			print("${len(names)} names: ${"<${names[0]}>"} and ${names[1]}")`,
+			expected: "2 names: <ann> and bob\n",
+		},
+		{
+			name: "escaped",
+			program: `
+			x = 1
+			print("\${x} is ${x}, $x and $ stay")`,
+			expected: "${x} is 1, $x and $ stay\n",
+		},
+		{
+			name: "closures",
+			program: `
+			fun greeter(greeting):
+				return fun(name): return "${greeting}, ${name}!" end
+			end
+			print(greeter("Hello")("world"))`,
+			expected: "Hello, world!\n",
+		},
+		{
+			name: "multi_line_and_raw",
+			program: `
+			fun report(items):
+				return """
+					Items:
+					  ${len(items)} in total
+					  first: ${items[0]}
+					"""
+			end
+			print(report(["a", "b"]))
+			print(is_regex_match(r"^\d+\.\d+$", "3.14"), r"${x}\n")`,
+			expected: "Items:\n  2 in total\n  first: a\ntrue ${x}\\n\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestStringInterpolationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"runtime_error_column", `print("x = ${1 / 0}")`, "value error at 1:16: can't divide by zero"},
+		{"name_error_column", "x = 1\nprint('${x} and ${y}')", "name error at 2:19: name \"y\" not found"},
+		{"multi_line_column", "print(\"\"\"\n  one\n  two ${1 % 0}\n  \"\"\")", "value error at 3:11: can't divide by zero"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, test.program, test.message)
+		})
+	}
+}
+
+func TestStringInterpolationParseErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{`print("${}")`, "parse error at 1:10: unexpected token }"},
+		{`print("${x y}")`, "parse error at 1:12: expected } to end interpolation in string, got name"},
+		{`print("${x")`, "parse error at 1:11: didn't find end quote in string"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseProgram([]byte(test.input))
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected error containing %q for %s, got %v", test.message, test.input, err)
+		}
+	}
+}
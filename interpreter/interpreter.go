@@ -12,13 +12,13 @@ import (
 // This includes nil, boolean, integer, float, string, array, object, and function values.
 type Value any
 
-
-
 // interpreter represents the internal state of the interpreter.
 // It maintains variable scopes, I/O streams, and execution statistics.
 type interpreter struct {
-	// vars is a stack of variable scopes, with the most local scope at the end
-	vars []map[string]Value
+	// globals holds the variables of the global scope, including builtins
+	globals map[string]Value
+	// env holds the local variables of the running function; nil at the top level
+	env *environment
 	// args holds command-line arguments for the args() builtin
 	args []string
 	// stdin is the input stream for the read() builtin
@@ -30,18 +30,63 @@ type interpreter struct {
 	// stats tracks execution statistics
 	stats Stats
 	inUnitTest bool
+	// vm runs compiled code; nil when the tree-walking engine is selected
+	vm *vm
+	// limits holds the resource limits from the config
+	limits limits
+	// calls holds the user function calls in progress, innermost last
+	calls []callFrame
+	// callBase is len(calls) when the running generator body was last
+	// resumed on the tree-walker, which may be at a different depth each time
+	callBase int
+	// generator is the generator whose body the tree-walker is running
+	generator *generator
+	// generators holds the generators started by the tree-walker
+	generators []*generator
+	// program is the program being executed, which keeps the source code of
+	// the files it imports; nil when evaluating an expression
+	program *Program
 }
 
-// returnResult is used to handle return statements in functions.
-// When a return statement is executed, it's wrapped in this struct and thrown as a panic,
-// which is then caught by the function's call handler.
-type returnResult struct {
-	// value is the value being returned
+// completionKind describes how the execution of a statement finished.
+type completionKind int
+
+const (
+	// completionNormal means execution continues with the next statement
+	completionNormal completionKind = iota
+	// completionReturn means a return statement was executed
+	completionReturn
+	// completionBreak means a break statement was executed
+	completionBreak
+	// completionContinue means a continue statement was executed
+	completionContinue
+)
+
+// completion is the result of executing a statement. Return, break and
+// continue are propagated as completions up to the function call or loop
+// that handles them, rather than unwinding the Go stack with a panic.
+type completion struct {
+	// kind is how the statement finished
+	kind completionKind
+	// value is the value being returned, for completionReturn
 	value Value
-	// pos is the position of the return statement in the source code
+	// pos is the position of the return, break or continue statement
 	pos Position
 }
 
+// raiseStray turns a return, break or continue that escaped to a place where
+// it isn't allowed into an error.
+func raiseStray(result completion) {
+	switch result.kind {
+	case completionReturn:
+		panic(runtimeError(result.pos, "can't return at top level"))
+	case completionBreak:
+		panic(BreakException{result.pos})
+	case completionContinue:
+		panic(ContinueException{result.pos})
+	}
+}
+
 // binaryEvalFunc is a function type for evaluating binary operations.
 // It takes the position of the operation and two operand values, and returns the result.
 type binaryEvalFunc func(pos Position, l, r Value) Value
@@ -61,6 +106,12 @@ var binaryEvalFuncs = map[Token]binaryEvalFunc{
 	NOTEQUAL: func(pos Position, l, r Value) Value { return !evalEqual(pos, l, r).(bool) }, // Inequality operator: !=
 	PLUS:     evalPlus,                                                                     // Addition operator: +
 	TIMES:    evalTimes,                                                                    // Multiplication operator: *
+	POWER:    evalPower,                                                                    // Power operator: **
+	BITAND:   evalBitwise("&", func(l, r int) int { return l & r }),                        // Bitwise AND: &
+	BITOR:    evalBitwise("|", func(l, r int) int { return l | r }),                        // Bitwise OR: |
+	BITXOR:   evalBitwise("^", func(l, r int) int { return l ^ r }),                        // Bitwise XOR: ^
+	SHL:      evalShift("<<", func(l, r int) int { return l << r }),                        // Left shift: <<
+	SHR:      evalShift(">>", func(l, r int) int { return l >> r }),                        // Right shift: >>
 }
 
 // ensureIntToFloats converts integer or float operands to float64 for arithmetic operations.
@@ -109,6 +160,7 @@ func ensureIntToFloats(pos Position, l, r Value, operation string) (float64, flo
 // Returns:
 //   - A boolean Value indicating whether the values are equal
 func evalEqual(pos Position, l, r Value) Value {
+	l, r = errorText(l, r), errorText(r, l)
 	switch l := l.(type) {
 	case nil:
 		// nil is only equal to nil
@@ -162,22 +214,29 @@ func evalEqual(pos Position, l, r Value) Value {
 			return Value(true)
 		}
 
-	case map[string]Value:
-		// Object/map equality (deep comparison)
-		if r, ok := r.(map[string]Value); ok {
-			// Maps must have the same size
-			if len(l) != len(r) {
+	case *Object:
+		// Object equality (deep comparison, regardless of key order)
+		if r, ok := r.(*Object); ok {
+			// Objects must have the same size
+			if l.Len() != r.Len() {
 				return Value(false)
 			}
 			// Compare each key-value pair recursively
-			for k, v := range l {
-				if !evalEqual(pos, v, r[k]).(bool) {
+			for _, k := range l.keys {
+				rv, ok := r.Get(k)
+				if !ok || !evalEqual(pos, l.values[k], rv).(bool) {
 					return Value(false)
 				}
 			}
 			return Value(true)
 		}
 
+	case *errorObject:
+		// Caught error equality (identity comparison)
+		if r, ok := r.(*errorObject); ok {
+			return Value(l == r)
+		}
+
 	case functionType:
 		// Function equality (identity comparison)
 		if r, ok := r.(functionType); ok {
@@ -220,13 +279,17 @@ func evalIn(pos Position, l, r Value) Value {
 		}
 		return Value(false)
 
-	case map[string]Value:
-		// Object/map containment: check if l is a key in r
+	case *Object:
+		// Object containment: check if l is a key in r
 		if l, ok := l.(string); ok {
-			_, present := r[l]
+			_, present := r.Get(l)
 			return Value(present)
 		}
 		panic(typeError(pos, "in object requires string on left side"))
+
+	case *errorObject:
+		// Caught errors contain their field names
+		return evalIn(pos, l, r.fields)
 	}
 
 	// The 'in' operator only works with strings, arrays, or objects on the right side
@@ -248,6 +311,7 @@ func evalIn(pos Position, l, r Value) Value {
 // Returns:
 //   - A boolean Value indicating whether l < r
 func evalLess(pos Position, l, r Value) Value {
+	l, r = errorText(l, r), errorText(r, l)
 	switch l := l.(type) {
 	case int:
 		// Integer comparison
@@ -294,6 +358,7 @@ func evalLess(pos Position, l, r Value) Value {
 }
 
 func evalPlus(pos Position, l, r Value) Value {
+	l, r = errorText(l, r), errorText(r, l)
 	switch l := l.(type) {
 	case int:
 		if r, ok := r.(int); ok {
@@ -320,14 +385,14 @@ func evalPlus(pos Position, l, r Value) Value {
 			result = append(result, *r...)
 			return Value(&result)
 		}
-	case map[string]Value:
-		if r, ok := r.(map[string]Value); ok {
-			result := make(map[string]Value)
-			for k, v := range l {
-				result[k] = v
+	case *Object:
+		if r, ok := r.(*Object); ok {
+			result := NewObject()
+			for _, k := range l.keys {
+				result.Set(k, l.values[k])
 			}
-			for k, v := range r {
-				result[k] = v
+			for _, k := range r.keys {
+				result.Set(k, r.values[k])
 			}
 			return Value(result)
 		}
@@ -418,13 +483,59 @@ func evalModulo(pos Position, l, r Value) Value {
 	return Value(int(li) % int(ri))
 }
 
+// evalPower evaluates the power operator (**). An integer raised to a
+// non-negative integer power is an integer; anything else is a float.
+func evalPower(pos Position, l, r Value) Value {
+	if base, ok := l.(int); ok {
+		if exp, ok := r.(int); ok && exp >= 0 {
+			// Exponentiation by squaring, wrapping around on overflow like *
+			result := 1
+			for ; exp > 0; exp >>= 1 {
+				if exp&1 == 1 {
+					result *= base
+				}
+				base *= base
+			}
+			return Value(result)
+		}
+	}
+	base, exp := ensureIntToFloats(pos, l, r, "**")
+	return Value(math.Pow(base, exp))
+}
+
+// evalBitwise returns the evaluation function of a bitwise operator, which
+// requires two integers.
+func evalBitwise(operator string, f func(l, r int) int) binaryEvalFunc {
+	return func(pos Position, l, r Value) Value {
+		li, lok := l.(int)
+		ri, rok := r.(int)
+		if !lok || !rok {
+			panic(typeError(pos, "%s requires two integers, got %s and %s", operator, typeName(l), typeName(r)))
+		}
+		return Value(f(li, ri))
+	}
+}
+
+// evalShift returns the evaluation function of a shift operator, which
+// requires two integers and a shift count that isn't negative.
+func evalShift(operator string, f func(l, r int) int) binaryEvalFunc {
+	bitwise := evalBitwise(operator, f)
+	return func(pos Position, l, r Value) Value {
+		if ri, ok := r.(int); ok && ri < 0 {
+			panic(valueError(pos, "%s requires a non-negative shift count, got %d", operator, ri))
+		}
+		return bitwise(pos, l, r)
+	}
+}
+
 // Unary operator evaluation functions
 type unaryEvalFunc func(pos Position, v Value) Value
 
 // Map of unary operator evaluation functions
 var unaryEvalFuncs = map[Token]unaryEvalFunc{
-	NOT:   evalNot,
-	MINUS: evalNegative,
+	NOT:    evalNot,
+	MINUS:  evalNegative,
+	BITNOT: evalBitNot,
 }
 
 // Unary operator not evaluation function
@@ -446,8 +557,19 @@ func evalNegative(pos Position, v Value) Value {
 	panic(typeError(pos, "unary - requires an integer or float"))
 }
 
+// Unary operator bitwise NOT evaluation function
+func evalBitNot(pos Position, v Value) Value {
+	if vi, ok := v.(int); ok {
+		return Value(^vi)
+	}
+	panic(typeError(pos, "~ requires an integer, got %s", typeName(v)))
+}
+
 // Function type for subscript evaluation
 func evalSubscript(pos Position, container, subscript Value) Value {
+	if bounds, ok := subscript.(sliceBounds); ok {
+		return evalSlice(pos, container, bounds)
+	}
 	switch c := container.(type) {
 	case string:
 		if s, ok := subscript.(int); ok {
@@ -473,19 +595,37 @@ func evalSubscript(pos Position, container, subscript Value) Value {
 			return (*c)[s]
 		}
 		panic(typeError(pos, "array subscript must be an integer"))
-	case map[string]Value:
+	case *Object:
 		if s, ok := subscript.(string); ok {
-			if value, ok := c[s]; ok {
+			if value, ok := c.Get(s); ok {
 				return value
 			}
 			panic(valueError(pos, "key not found: %q", s))
 		}
 		panic(typeError(pos, "object subscript must be a string"))
+	case *errorObject:
+		return evalSubscript(pos, c.fields, subscript)
 	default:
 		panic(typeError(pos, "can only subscript string, array, or object"))
 	}
 }
 
+// evalOptionalSubscript is evalSubscript for the optional links of optional
+// chains (a?.b) and the targets of ??=, where a key missing from an object
+// counts as null instead of being an error.
+func evalOptionalSubscript(pos Position, container, subscript Value) Value {
+	if e, ok := container.(*errorObject); ok {
+		container = e.fields
+	}
+	if obj, ok := container.(*Object); ok {
+		if key, ok := subscript.(string); ok {
+			value, _ := obj.Get(key)
+			return value
+		}
+	}
+	return evalSubscript(pos, container, subscript)
+}
+
 func (interp *interpreter) evalAnd(pos Position, le, re Expression) Value {
 	l := interp.evaluate(le)
 	if l, ok := l.(bool); ok {
@@ -534,21 +674,28 @@ func (interp *interpreter) evalXor(_ Position, le, re Expression) Value {
 	return Value(leftTruthy != rightTruthy)
 }
 
-func (interp *interpreter) callFunction(pos Position, f functionType, args []Value) (ret Value) {
-	defer func() {
-		if r := recover(); r != nil {
-			if result, ok := r.(returnResult); ok {
-				ret = result.value
-			} else {
-				panic(r)
-			}
-		}
-	}()
+// evalNullish evaluates the ?? operator, which only evaluates the right
+// operand if the left one is null.
+func (interp *interpreter) evalNullish(le, re Expression) Value {
+	if l := interp.evaluate(le); l != nil {
+		return l
+	}
+	return interp.evaluate(re)
+}
+
+// shortCircuit is the value of the calls and subscripts of an optional chain
+// after one of its optional links has found null. The chain evaluates to null.
+type shortCircuit struct{}
+
+func (interp *interpreter) callFunction(pos Position, f functionType, args []Value) Value {
 	return f.call(interp, pos, args)
 }
 
 func (interp *interpreter) evaluate(expr Expression) Value {
 	interp.stats.Ops++
+	if interp.stats.Ops >= interp.limits.nextCheck {
+		interp.checkLimits(expr.Position())
+	}
 	switch e := expr.(type) {
 	case *Binary:
 		if f, ok := binaryEvalFuncs[e.Operator]; ok {
@@ -559,6 +706,8 @@ func (interp *interpreter) evaluate(expr Expression) Value {
 			return interp.evalOr(e.Position(), e.Left, e.Right)
 		} else if e.Operator == XOR {
 			return interp.evalXor(e.Position(), e.Left, e.Right)
+		} else if e.Operator == NULLISH {
+			return interp.evalNullish(e.Left, e.Right)
 		}
 		// Parser should never give us this
 		panic(fmt.Sprintf("unknown binary operator %v", e.Operator))
@@ -577,40 +726,66 @@ func (interp *interpreter) evaluate(expr Expression) Value {
 		}
 	case *Call:
 		function := interp.evaluate(e.Function)
+		if function == (shortCircuit{}) || function == nil && e.Optional {
+			return shortCircuit{}
+		}
 		if f, ok := function.(functionType); ok {
 			args := []Value{}
 			for _, a := range e.Arguments {
 				args = append(args, interp.evaluate(a))
 			}
+			positional := len(args) - len(e.Names)
+			named := args[positional:]
+			args = args[:positional:positional]
 			if e.Ellipsis {
-				iterator := getIterator(e.Arguments[len(args)-1].Position(), args[len(args)-1])
-				args = args[:len(args)-1]
+				iterator := interp.getIterator(e.Arguments[positional-1].Position(), args[positional-1])
+				args = args[:positional-1]
 				for iterator.HasNext() {
 					args = append(args, iterator.Value())
 				}
 			}
+			if len(e.Names) > 0 {
+				args = placeNamedArgs(e.Function.Position(), f, args, e.Names, named)
+			}
 			return interp.callFunction(e.Function.Position(), f, args)
 		}
 		panic(typeError(e.Function.Position(), "can't call non-function type %s", typeName(function)))
 	case *Literal:
 		return Value(e.Value)
+	case *Interpolation:
+		var sb strings.Builder
+		sb.WriteString(e.Strings[0])
+		for i, v := range e.Values {
+			sb.WriteString(toString(interp.evaluate(v), false))
+			sb.WriteString(e.Strings[i+1])
+		}
+		return Value(sb.String())
 	case *Variable:
-		if v, ok := interp.lookup(e.Name); ok {
+		if v, ok := interp.lookupVariable(e.binding, e.Name); ok {
 			return v
 		}
 		panic(nameError(e.Position(), "name %q not found", e.Name))
 	case *List:
-		values := make([]Value, len(e.Values))
-		for i, v := range e.Values {
-			values[i] = interp.evaluate(v)
+		values := make([]Value, 0, len(e.Values))
+		for _, v := range e.Values {
+			if spread, ok := v.(*Spread); ok {
+				values = interp.spreadValues(spread.Position(), values, interp.evaluate(spread.Value))
+				continue
+			}
+			values = append(values, interp.evaluate(v))
 		}
 		return Value(&values)
 	case *Map:
-		value := make(map[string]Value)
+		value := NewObject()
 		for _, item := range e.Items {
+			if item.Key == nil {
+				spread := item.Value.(*Spread)
+				spreadObject(spread.Position(), value, interp.evaluate(spread.Value))
+				continue
+			}
 			key := interp.evaluate(item.Key)
 			if k, ok := key.(string); ok {
-				value[k] = interp.evaluate(item.Value)
+				value.Set(k, interp.evaluate(item.Value))
 			} else {
 				panic(typeError(item.Key.Position(), "object key must be string, not %s", typeName(key)))
 			}
@@ -618,43 +793,119 @@ func (interp *interpreter) evaluate(expr Expression) Value {
 		return Value(value)
 	case *Subscript:
 		container := interp.evaluate(e.Container)
+		if container == (shortCircuit{}) || container == nil && e.Optional {
+			return shortCircuit{}
+		}
 		subscript := interp.evaluate(e.Subscript)
+		if e.Optional {
+			return evalOptionalSubscript(e.Subscript.Position(), container, subscript)
+		}
 		return evalSubscript(e.Subscript.Position(), container, subscript)
+	case *OptionalChain:
+		if value := interp.evaluate(e.Expression); value != (shortCircuit{}) {
+			return value
+		}
+		return nil
+	case *Slice:
+		bounds := sliceBounds{}
+		if e.Start != nil {
+			bounds.start = interp.evaluate(e.Start)
+		}
+		if e.End != nil {
+			bounds.end = interp.evaluate(e.End)
+		}
+		if e.Step != nil {
+			bounds.step = interp.evaluate(e.Step)
+		}
+		return Value(bounds)
+	case *Comprehension:
+		env := interp.env
+		interp.env = newEnvironment(e.scope, env)
+		if e.Element.Key != nil {
+			interp.env.slots[0] = NewObject()
+		} else {
+			interp.env.slots[0] = &[]Value{}
+		}
+		interp.executeStatement(e.Loop)
+		result := interp.env.slots[0]
+		interp.env = env
+		return result
 	case *FunctionExpression:
-		closure := interp.vars[len(interp.vars)-1]
-		return &userFunction{"", e.Parameters, e.Ellipsis, e.Body, closure}
+		return &userFunction{"", e.Parameters, e.Defaults, e.Ellipsis, e.Body, e.prologue, interp.env, e.scope, nil}
 	default:
 		// Parser should never get us here
 		panic(fmt.Sprintf("unexpected expression type %T", expr))
 	}
 }
 
-func (interp *interpreter) pushScope(scope map[string]Value) {
-	interp.vars = append(interp.vars, scope)
+// environment holds the local variables of one function call, in the slots
+// laid out by the resolver. parent is the environment the function was
+// created in, or nil for functions created at the top level.
+type environment struct {
+	slots  []Value
+	scope  *scope
+	parent *environment
 }
 
-func (interp *interpreter) popScope() {
-	interp.vars = interp.vars[:len(interp.vars)-1]
-}
+// unboundValue marks a local variable slot that hasn't been assigned yet.
+type unboundValue struct{}
 
-func (interp *interpreter) assign(name string, value Value) {
-	interp.vars[len(interp.vars)-1][name] = value
+func newEnvironment(scope *scope, parent *environment) *environment {
+	slots := make([]Value, len(scope.names))
+	for i := range slots {
+		slots[i] = unboundValue{}
+	}
+	return &environment{slots, scope, parent}
 }
 
-func (interp *interpreter) lookup(name string) (Value, bool) {
-	for i := len(interp.vars) - 1; i >= 0; i-- {
-		thisVars := interp.vars[i]
-		if v, ok := thisVars[name]; ok {
+// lookupVariable returns the value of a resolved variable. A local that
+// hasn't been assigned yet falls back to the global of the same name.
+func (interp *interpreter) lookupVariable(b binding, name string) (Value, bool) {
+	if b.depth != globalDepth {
+		env := interp.env
+		for i := 0; i < b.depth; i++ {
+			env = env.parent
+		}
+		if v := env.slots[b.index]; v != (unboundValue{}) {
 			return v, true
 		}
 	}
-	return nil, false
+	return interp.lookup(name)
 }
 
-func (interp *interpreter) executeBlock(block Block) {
+// assignVariable stores a value in a resolved variable.
+func (interp *interpreter) assignVariable(b binding, name string, value Value) {
+	if b.depth == globalDepth {
+		interp.globals[name] = value
+		return
+	}
+	env := interp.env
+	for i := 0; i < b.depth; i++ {
+		env = env.parent
+	}
+	env.slots[b.index] = value
+}
+
+// assign sets a variable in the global scope.
+func (interp *interpreter) assign(name string, value Value) {
+	interp.globals[name] = value
+}
+
+// lookup returns the value of a variable in the global scope.
+func (interp *interpreter) lookup(name string) (Value, bool) {
+	v, ok := interp.globals[name]
+	return v, ok
+}
+
+// executeBlock executes the statements of a block in order, stopping at the
+// first one that doesn't complete normally.
+func (interp *interpreter) executeBlock(block Block) completion {
 	for _, s := range block {
-		interp.executeStatement(s)
+		if result := interp.executeStatement(s); result.kind != completionNormal {
+			return result
+		}
 	}
+	return completion{}
 }
 
 type iteratorType interface {
@@ -677,7 +928,43 @@ func (li *listIterator) Value() Value {
 	return v
 }
 
-func getIterator(pos Position, value Value) iteratorType {
+// protocolIterator iterates over an object with a next function, like the
+// iterator of a generator. Each call to next returns an object whose done
+// field is true once there are no more values, and whose value field holds
+// the next value otherwise.
+type protocolIterator struct {
+	interp *interpreter
+	pos    Position
+	next   functionType
+	// value is the value fetched by HasNext for Value to return
+	value   Value
+	fetched bool
+	done    bool
+}
+
+func (pi *protocolIterator) HasNext() bool {
+	if !pi.fetched && !pi.done {
+		result := pi.interp.callFunction(pi.pos, pi.next, nil)
+		obj, ok := result.(*Object)
+		if !ok {
+			panic(typeError(pi.pos, "iterator next() must return an object, got %s", typeName(result)))
+		}
+		done, ok := obj.values["done"].(bool)
+		if !ok {
+			panic(typeError(pi.pos, "iterator next() must return an object with a bool done field"))
+		}
+		pi.value, pi.fetched, pi.done = obj.values["value"], !done, done
+	}
+	return !pi.done
+}
+
+func (pi *protocolIterator) Value() Value {
+	pi.HasNext()
+	pi.fetched = false
+	return pi.value
+}
+
+func (interp *interpreter) getIterator(pos Position, value Value) iteratorType {
 	switch iterable := value.(type) {
 	case string:
 		strs := []Value{}
@@ -687,23 +974,89 @@ func getIterator(pos Position, value Value) iteratorType {
 		return &listIterator{strs, 0}
 	case *[]Value:
 		return &listIterator{*iterable, 0}
-	case map[string]Value:
-		keys := make([]Value, len(iterable))
-		i := 0
-		for key := range iterable {
+	case *Object:
+		// Objects with a next function follow the iterator protocol
+		if next, ok := iterable.values["next"].(functionType); ok {
+			return &protocolIterator{interp: interp, pos: pos, next: next}
+		}
+		keys := make([]Value, len(iterable.keys))
+		for i, key := range iterable.keys {
 			keys[i] = key
-			i++
 		}
 		return &listIterator{keys, 0}
+	case *errorObject:
+		return interp.getIterator(pos, iterable.fields)
 	default:
-		panic(typeError(pos, "expected iterable (string, array, or object), got %s", typeName(value)))
+		panic(typeError(pos, "expected iterable (string, array, object or iterator), got %s", typeName(value)))
 	}
 }
 
+// entryIterator iterates over the values of an iterable, also giving the
+// index or key of each value.
+type entryIterator struct {
+	iteratorType
+	// keys holds the keys of an object, or is nil for iterables whose keys
+	// are the indexes of their values
+	keys  []Value
+	index int
+}
+
+// Key returns the index or key of the value Value returns next.
+func (ei *entryIterator) Key() Value {
+	if ei.keys == nil {
+		return ei.index
+	}
+	return ei.keys[ei.index]
+}
+
+func (ei *entryIterator) Value() Value {
+	v := ei.iteratorType.Value()
+	ei.index++
+	return v
+}
+
+// spreadValues appends the values of an iterable spread into an array
+// literal to values.
+func (interp *interpreter) spreadValues(pos Position, values []Value, iterable Value) []Value {
+	iterator := interp.getIterator(pos, iterable)
+	for iterator.HasNext() {
+		values = append(values, iterator.Value())
+	}
+	return values
+}
+
+// getEntryIterator returns the iterator of a two-variable for loop.
+func (interp *interpreter) getEntryIterator(pos Position, value Value) *entryIterator {
+	iterator := interp.getIterator(pos, value)
+	switch iterable := value.(type) {
+	case *Object:
+		if _, ok := iterator.(*protocolIterator); ok {
+			break
+		}
+		keys := iterator.(*listIterator).values
+		values := make([]Value, len(keys))
+		for i, key := range keys {
+			values[i] = iterable.values[key.(string)]
+		}
+		return &entryIterator{&listIterator{values, 0}, keys, 0}
+	case *errorObject:
+		return interp.getEntryIterator(pos, iterable.fields)
+	}
+	return &entryIterator{iterator, nil, 0}
+}
+
 func (interp *interpreter) assignSubscript(pos Position, container, subscript, value Value) {
+	if bounds, ok := subscript.(sliceBounds); ok {
+		interp.assignSlice(pos, container, bounds, value)
+		return
+	}
 	switch c := container.(type) {
 	case *[]Value:
 		if s, ok := subscript.(int); ok {
+			// Handle negative indexing for arrays
+			if s < 0 {
+				s = len(*c) + s
+			}
 			if s < 0 || s >= len(*c) {
 				panic(valueError(pos, "subscript %d out of range", s))
 			}
@@ -711,98 +1064,115 @@ func (interp *interpreter) assignSubscript(pos Position, container, subscript, v
 		} else {
 			panic(typeError(pos, "array subscript must be an integer"))
 		}
-	case map[string]Value:
+	case *Object:
 		if s, ok := subscript.(string); ok {
-			c[s] = value
+			c.Set(s, value)
 		} else {
 			panic(typeError(pos, "object subscript must be a string"))
 		}
+	case *errorObject:
+		panic(typeError(pos, "caught errors are read-only"))
 	default:
 		panic(typeError(pos, "can only assign to subscript of array or object"))
 	}
 }
 
-// evaluateAssignmentValue evaluates assignment operators like +=, -=, etc.
-func (interp *interpreter) evaluateAssignmentValue(operator Token, target interface{}, value Expression) Value {
-	rightValue := interp.evaluate(value)
-
-	// For simple assignment, just return the right value
-	if operator == ASSIGN {
-		return rightValue
-	}
-
-	// For compound assignments, get current value and perform operation
-	var currentValue Value
-	switch t := target.(type) {
-	case string: // Variable name
-		if v, ok := interp.lookup(t); ok {
-			currentValue = v
-		} else {
-			panic(nameError(value.Position(), "name %q not found", t))
-		}
-	default:
-		panic("unsupported assignment target type")
-	}
-
-	// Perform the compound operation
+// evalCompound applies the binary operation of a compound assignment
+// operator like += or -= to the current and right-hand values.
+func evalCompound(pos Position, operator Token, currentValue, rightValue Value) Value {
 	switch operator {
 	case PLUSEQUAL:
-		return evalPlus(value.Position(), currentValue, rightValue)
+		return evalPlus(pos, currentValue, rightValue)
 	case MINUSEQUAL:
-		return evalMinus(value.Position(), currentValue, rightValue)
+		return evalMinus(pos, currentValue, rightValue)
 	case TIMESEQUAL:
-		return evalTimes(value.Position(), currentValue, rightValue)
+		return evalTimes(pos, currentValue, rightValue)
 	case DIVIDEEQUAL:
-		return evalDivide(value.Position(), currentValue, rightValue)
+		return evalDivide(pos, currentValue, rightValue)
 	case MODULOEQUAL:
-		return evalModulo(value.Position(), currentValue, rightValue)
+		return evalModulo(pos, currentValue, rightValue)
+	case POWEREQUAL:
+		return evalPower(pos, currentValue, rightValue)
+	case BITANDEQUAL:
+		return binaryEvalFuncs[BITAND](pos, currentValue, rightValue)
+	case BITOREQUAL:
+		return binaryEvalFuncs[BITOR](pos, currentValue, rightValue)
+	case BITXOREQUAL:
+		return binaryEvalFuncs[BITXOR](pos, currentValue, rightValue)
+	case SHLEQUAL:
+		return binaryEvalFuncs[SHL](pos, currentValue, rightValue)
+	case SHREQUAL:
+		return binaryEvalFuncs[SHR](pos, currentValue, rightValue)
 	default:
 		panic(fmt.Sprintf("unknown assignment operator %v", operator))
 	}
 }
 
+// evaluateAssignmentValue evaluates assignment operators like +=, -=, etc.
+func (interp *interpreter) evaluateAssignmentValue(operator Token, target *Variable, value Expression) Value {
+	rightValue := interp.evaluate(value)
+
+	// For simple assignment, just return the right value. ??= only gets
+	// here if the current value is null.
+	if operator == ASSIGN || operator == NULLISHEQUAL {
+		return rightValue
+	}
+
+	// For compound assignments, get current value and perform operation
+	currentValue, ok := interp.lookupVariable(target.binding, target.Name)
+	if !ok {
+		panic(nameError(value.Position(), "name %q not found", target.Name))
+	}
+
+	return evalCompound(value.Position(), operator, currentValue, rightValue)
+}
+
 // evaluateSubscriptAssignmentValue evaluates assignment operators for subscripts
 func (interp *interpreter) evaluateSubscriptAssignmentValue(operator Token, container, subscript Value, value Expression) Value {
 	rightValue := interp.evaluate(value)
 
-	// For simple assignment, just return the right value
-	if operator == ASSIGN {
+	// For simple assignment, just return the right value. ??= only gets
+	// here if the current value is null.
+	if operator == ASSIGN || operator == NULLISHEQUAL {
 		return rightValue
 	}
 
 	// For compound assignments, get current value and perform operation
 	currentValue := evalSubscript(value.Position(), container, subscript)
-
-	// Perform the compound operation
-	switch operator {
-	case PLUSEQUAL:
-		return evalPlus(value.Position(), currentValue, rightValue)
-	case MINUSEQUAL:
-		return evalMinus(value.Position(), currentValue, rightValue)
-	case TIMESEQUAL:
-		return evalTimes(value.Position(), currentValue, rightValue)
-	case DIVIDEEQUAL:
-		return evalDivide(value.Position(), currentValue, rightValue)
-	case MODULOEQUAL:
-		return evalModulo(value.Position(), currentValue, rightValue)
-	default:
-		panic(fmt.Sprintf("unknown assignment operator %v", operator))
-	}
+	return evalCompound(value.Position(), operator, currentValue, rightValue)
 }
 
-func (interp *interpreter) executeStatement(s Statement) {
+func (interp *interpreter) executeStatement(s Statement) completion {
 	interp.stats.Ops++
+	if interp.stats.Ops >= interp.limits.nextCheck {
+		interp.checkLimits(s.Position())
+	}
 	switch s := s.(type) {
 	case *Assign:
 		switch target := s.Target.(type) {
 		case *Variable:
-			newValue := interp.evaluateAssignmentValue(s.Operator, target.Name, s.Value)
-			interp.assign(target.Name, newValue)
+			if s.Operator == NULLISHEQUAL {
+				current, ok := interp.lookupVariable(target.binding, target.Name)
+				if !ok {
+					panic(nameError(s.Value.Position(), "name %q not found", target.Name))
+				}
+				if current != nil {
+					// ??= leaves values other than null alone
+					break
+				}
+			}
+			newValue := interp.evaluateAssignmentValue(s.Operator, target, s.Value)
+			interp.assignVariable(target.binding, target.Name, newValue)
 		case *Subscript:
 			container := interp.evaluate(target.Container)
 			subscript := interp.evaluate(target.Subscript)
+			if s.Operator == NULLISHEQUAL && evalOptionalSubscript(s.Value.Position(), container, subscript) != nil {
+				break
+			}
 			newValue := interp.evaluateSubscriptAssignmentValue(s.Operator, container, subscript, s.Value)
 			interp.assignSubscript(target.Subscript.Position(), container, subscript, newValue)
+		case *ArrayTarget, *ObjectTarget:
+			interp.assignTarget(target, interp.evaluate(s.Value))
 		default:
 			// Parser should never get us here
 			panic("can only assign to variable or subscript")
@@ -811,160 +1181,178 @@ func (interp *interpreter) executeStatement(s Statement) {
 		cond := interp.evaluate(s.Condition)
 		if c, ok := cond.(bool); ok {
 			if c {
-				interp.executeBlock(s.Body)
+				return interp.executeBlock(s.Body)
 			} else if len(s.Else) > 0 {
-				interp.executeBlock(s.Else)
+				return interp.executeBlock(s.Else)
 			}
 		} else {
 			panic(typeError(s.Condition.Position(), "if condition must be bool, got %s", typeName(cond)))
 		}
 	case *While:
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					if r == "__break__" {
-						// Normal break, just exit the loop
-						return
-					}
-					// Re-panic other exceptions
-					panic(r)
-				}
-			}()
-
-			for {
-				cond := interp.evaluate(s.Condition)
-				if c, ok := cond.(bool); ok {
-					if !c {
-						break
-					}
-					func() {
-						defer func() {
-							if r := recover(); r != nil {
-								switch r.(type) {
-								case BreakException:
-									// Break out of the loop
-									panic("__break__")
-								case ContinueException:
-									// Continue to next iteration
-									return
-								default:
-									// Re-panic other exceptions
-									panic(r)
-								}
-							}
-						}()
-						interp.executeBlock(s.Body)
-					}()
-				} else {
-					panic(typeError(s.Condition.Position(), "while condition must be bool, got %T", cond))
-				}
+		for {
+			cond := interp.evaluate(s.Condition)
+			c, ok := cond.(bool)
+			if !ok {
+				panic(typeError(s.Condition.Position(), "while condition must be bool, got %T", cond))
 			}
-		}()
+			if !c {
+				break
+			}
+			result := interp.executeBlock(s.Body)
+			if result.kind == completionBreak {
+				break
+			}
+			if result.kind == completionReturn {
+				return result
+			}
+		}
 	case *For:
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					if r == "__break__" {
-						// Normal break, just exit the loop
-						return
-					}
-					// Re-panic other exceptions
-					panic(r)
-				}
-			}()
-
-			iterable := interp.evaluate(s.Iterable)
-			iterator := getIterator(s.Iterable.Position(), iterable)
-			for iterator.HasNext() {
-				interp.assign(s.Name, iterator.Value())
-				func() {
-					defer func() {
-						if r := recover(); r != nil {
-							switch r.(type) {
-							case BreakException:
-								// Break out of the loop
-								panic("__break__")
-							case ContinueException:
-								// Continue to next iteration
-								return
-							default:
-								// Re-panic other exceptions
-								panic(r)
-							}
-						}
-					}()
-					interp.executeBlock(s.Body)
-				}()
+		iterable := interp.evaluate(s.Iterable)
+		var iterator iteratorType
+		if s.Key != "" {
+			iterator = interp.getEntryIterator(s.Iterable.Position(), iterable)
+		} else {
+			iterator = interp.getIterator(s.Iterable.Position(), iterable)
+		}
+		for iterator.HasNext() {
+			if s.Key != "" {
+				interp.assignVariable(s.keyBinding, s.Key, iterator.(*entryIterator).Key())
 			}
-		}()
+			if s.Target != nil {
+				interp.assignTarget(s.Target, iterator.Value())
+			} else {
+				interp.assignVariable(s.binding, s.Name, iterator.Value())
+			}
+			result := interp.executeBlock(s.Body)
+			if result.kind == completionBreak {
+				break
+			}
+			if result.kind == completionReturn {
+				return result
+			}
+		}
+	case *Match:
+		return interp.executeMatch(s)
 	case *TryCatch:
-		// Create a new scope for the try block
-		interp.pushScope(make(map[string]Value))
-
 		// Execute the try block and catch any errors
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					// Pop the try scope
-					interp.popScope()
-
-					// Create a new scope for the catch block
-					interp.pushScope(make(map[string]Value))
-
-					// Assign the error to the error variable
-					var errValue Value
-					switch e := r.(type) {
-					case Error:
-						errValue = e.Error()
-					case error:
-						errValue = e.Error()
-					case returnResult:
-						// Re-panic return statements to handle them normally
-						panic(r)
-					default:
-						errValue = fmt.Sprintf("%v", r)
-					}
-
-					// Assign the error to the catch variable
-					interp.assign(s.ErrVar, errValue)
-
-					// Execute the catch block
-					interp.executeBlock(s.CatchBlock)
-
-					// Pop the catch scope
-					interp.popScope()
-				}
-			}()
-
-			// Execute the try block
-			interp.executeBlock(s.TryBlock)
-
-			// Pop the try scope if no error occurred
-			interp.popScope()
-		}()
+		result, caught := interp.executeTry(s.TryBlock)
+		if caught != nil && s.ErrVar != "" {
+			// Assign the error to the catch variable and execute the catch block
+			interp.assignVariable(s.errBinding, s.ErrVar, newErrorObject(caught))
+			if s.FinallyBlock == nil {
+				return interp.executeBlock(s.CatchBlock)
+			}
+			result, caught = interp.executeTry(s.CatchBlock)
+		}
+		if s.FinallyBlock != nil {
+			// The finally block runs however the try or catch block was left,
+			// and a return, break or continue in it takes precedence
+			if final := interp.executeBlock(s.FinallyBlock); final.kind != completionNormal {
+				return final
+			}
+			if caught != nil {
+				panic(caught)
+			}
+		}
+		return result
 	case *ExpressionStatement:
 		interp.evaluate(s.Expression)
 	case *FunctionDefinition:
-		closure := interp.vars[len(interp.vars)-1]
-		interp.assign(s.Name, &userFunction{s.Name, s.Parameters, s.Ellipsis, s.Body, closure})
+		f := &userFunction{s.Name, s.Parameters, s.Defaults, s.Ellipsis, s.Body, s.prologue, interp.env, s.scope, nil}
+		interp.assignVariable(s.binding, s.Name, f)
 	case *Return:
-		result := interp.evaluate(s.Result)
-		panic(returnResult{result, s.Position()})
+		return completion{completionReturn, interp.evaluate(s.Result), s.Position()}
+	case *Throw:
+		throw(s.Position(), interp.evaluate(s.Value))
+	case *Element:
+		// The comprehension keeps the array or object it builds in slot 0
+		switch result := interp.env.slots[0].(type) {
+		case *[]Value:
+			*result = append(*result, interp.evaluate(s.Value))
+		case *Object:
+			key := interp.evaluate(s.Key)
+			value := interp.evaluate(s.Value)
+			if k, ok := key.(string); ok {
+				result.Set(k, value)
+			} else {
+				panic(typeError(s.Key.Position(), "object key must be string, not %s", typeName(key)))
+			}
+		}
+	case *DefaultValue:
+		if interp.env.slots[s.Parameter.binding.index] == (unboundValue{}) {
+			interp.assignVariable(s.Parameter.binding, s.Parameter.Name, interp.evaluate(s.Value))
+		}
+	case *Yield:
+		value := interp.evaluate(s.Value)
+		if interp.generator == nil {
+			panic(runtimeError(s.Position(), "can't yield outside of a generator"))
+		}
+		interp.generator.yield(interp, value)
 	case *Break:
-		panic(BreakException{s.Position()})
+		return completion{kind: completionBreak, pos: s.Position()}
 	case *Continue:
-		panic(ContinueException{s.Position()})
+		return completion{kind: completionContinue, pos: s.Position()}
 	case *Import:
 		interp.executeImport(s)
+	case *NameDeclaration:
+		// Applied by the resolver, nothing to do at run time
 	default:
 		// Parser should never get us here
 		panic(fmt.Sprintf("unexpected statement type %T", s))
 	}
+	return completion{}
+}
+
+// executeTry executes the try block of a TryCatch statement. If an error is
+// raised, the environment of the current function is restored and the
+// recovered value is returned as caught. A LimitError is never caught.
+func (interp *interpreter) executeTry(block Block) (result completion, caught any) {
+	// The call depth is kept relative to callBase, as the try block may be
+	// in a generator body resumed at a different depth
+	env, calls := interp.env, len(interp.calls)-interp.callBase
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(LimitError); ok {
+				panic(r)
+			}
+			caught = interp.attachStack(r)
+			interp.env, interp.calls = env, interp.calls[:interp.callBase+calls]
+		}
+	}()
+
+	return interp.executeBlock(block), nil
+}
+
+// throw raises a value thrown by a throw statement as a UserError. Throwing
+// a caught error raises the original error again, with its type and
+// traceback.
+func throw(pos Position, value Value) {
+	if e, ok := value.(*errorObject); ok {
+		panic(e.raised)
+	}
+	panic(userError(pos, value))
+}
+
+// executeTopLevel runs a list of top-level statements in the global scope
+// using the selected execution engine.
+func (interp *interpreter) executeTopLevel(name string, statements Block) {
+	if interp.vm != nil {
+		interp.vm.runCode(compileProgram(name, statements))
+		return
+	}
+	env := interp.env
+	interp.env = nil
+	for _, statement := range statements {
+		raiseStray(interp.executeStatement(statement))
+	}
+	interp.env = env
 }
 
 func (interp *interpreter) execute(prog *Program) {
-	for _, statement := range prog.Statements {
-		interp.executeStatement(statement)
+	if interp.vm != nil {
+		interp.vm.runCode(prog.compiled())
+	} else {
+		interp.executeTopLevel("<program>", prog.Statements)
 	}
 
 	// Automatically call the main function if it exists
@@ -980,25 +1368,32 @@ func (interp *interpreter) execute(prog *Program) {
 	}
 }
 
+// constants are the mathematical constants predefined in the global scope.
+var constants = map[string]Value{
+	"PI":    math.Pi,
+	"E":     math.E,
+	"TAU":   2 * math.Pi,
+	"PHI":   (1 + math.Sqrt(5)) / 2, // Golden ratio
+	"LN2":   math.Ln2,
+	"LN10":  math.Ln10,
+	"SQRT2": math.Sqrt2,
+	"SQRT3": math.Sqrt(3),
+}
+
 func newInterpreter(config *Config) *interpreter {
 	interp := new(interpreter)
-	interp.pushScope(make(map[string]Value))
+	interp.globals = make(map[string]Value, len(builtins)+len(constants))
 	for k, v := range builtins {
 		interp.assign(k, v)
 	}
 
 	// Add mathematical constants
-	interp.assign("PI", Value(math.Pi))
-	interp.assign("E", Value(math.E))
-	interp.assign("TAU", Value(2 * math.Pi))
-	interp.assign("PHI", Value((1 + math.Sqrt(5)) / 2))  // Golden ratio
-	interp.assign("LN2", Value(math.Ln2))
-	interp.assign("LN10", Value(math.Ln10))
-	interp.assign("SQRT2", Value(math.Sqrt2))
-	interp.assign("SQRT3", Value(math.Sqrt(3)))
+	for k, v := range constants {
+		interp.assign(k, v)
+	}
 
 	for k, v := range config.Vars {
-		interp.assign(k, v)
+		interp.assign(k, importValue(v))
 	}
 	interp.args = config.Args
 	interp.stdin = config.Stdin
@@ -1014,6 +1409,10 @@ func newInterpreter(config *Config) *interpreter {
 		interp.exit = os.Exit
 	}
 	interp.inUnitTest = config.IsUnitTest
+	interp.limits = newLimits(config)
+	if config.Engine == EngineVM {
+		interp.vm = newVM(interp)
+	}
 	return interp
 }
 
@@ -1022,14 +1421,29 @@ func newInterpreter(config *Config) *interpreter {
 // and an error which is nil on success or an interpreter.Error if there's an
 // error.
 func Evaluate(expr Expression, config *Config) (v Value, stats *Stats, err error) {
+	var interp *interpreter
 	defer func() {
 		if r := recover(); r != nil {
-			// Convert to interpreter.Error or re-panic
-			err = r.(Error)
+			if interp != nil {
+				r = interp.attachStack(r)
+			}
+			// Runtime errors and LimitErrors are returned, anything else is
+			// a bug and panics again
+			e, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			err = e
 		}
 	}()
-	interp := newInterpreter(config)
-	v = interp.evaluate(expr)
+	resolveExpression(expr)
+	interp = newInterpreter(config)
+	defer interp.closeGenerators()
+	if interp.vm != nil {
+		v = interp.vm.runCode(compileExpression(expr))
+	} else {
+		v = interp.evaluate(expr)
+	}
 	stats = &interp.stats
 	return
 }
@@ -1038,19 +1452,24 @@ func Evaluate(expr Expression, config *Config) (v Value, stats *Stats, err error
 // program. Return interpreter statistics, and an error which is nil on
 // success or an interpreter.Error if there's an error.
 func Execute(prog *Program, config *Config) (stats *Stats, err error) {
+	var interp *interpreter
 	defer func() {
 		if r := recover(); r != nil {
+			if interp != nil {
+				r = interp.attachStack(r)
+			}
 			switch e := r.(type) {
 			case Error:
 				err = e
-			case returnResult:
-				err = runtimeError(e.pos, "can't return at top level")
 			default:
 				err = r.(error)
 			}
 		}
 	}()
-	interp := newInterpreter(config)
+	resolveProgram(prog)
+	interp = newInterpreter(config)
+	interp.program = prog
+	defer interp.closeGenerators()
 	interp.execute(prog)
 	stats = &interp.stats
 	return
@@ -1058,24 +1477,44 @@ func Execute(prog *Program, config *Config) (stats *Stats, err error) {
 
 // executeImport handles importing and executing .din files
 func (interp *interpreter) executeImport(s *Import) {
+	prog := interp.loadImport(s.Position(), s.Filename)
+
+	// Execute the imported program
+	// Note: This will execute in the current scope, so variables and functions
+	// from the imported file will be available in the current context
+	interp.executeTopLevel(s.Filename, prog.Statements)
+}
+
+// loadImport reads and parses the file named by an import statement.
+func (interp *interpreter) loadImport(pos Position, filename string) *Program {
 	// Read the file content
-	content, err := os.ReadFile(s.Filename)
+	content, err := os.ReadFile(filename)
 	if err != nil {
-		panic(runtimeError(s.Position(), "failed to import file '%s': %s", s.Filename, err))
+		panic(runtimeError(pos, "failed to import file '%s': %s", filename, err))
 	}
 
 	// Parse the imported file
-	prog, err := ParseProgram(content)
+	prog, err := ParseProgramFile(filename, content)
 	if err != nil {
-		panic(runtimeError(s.Position(), "failed to parse imported file '%s': %s", s.Filename, err))
+		panic(runtimeError(pos, "failed to parse imported file '%s': %s", filename, err))
 	}
+	interp.registerSource(filename, content)
+	return prog
+}
 
-	// Execute the imported program
-	// Note: This will execute in the current scope, so variables and functions
-	// from the imported file will be available in the current context
-	for _, statement := range prog.Statements {
-		interp.executeStatement(statement)
+// registerSource records the source code of a file imported by the running
+// program, so that errors raised in it are shown with their source line.
+func (interp *interpreter) registerSource(filename string, source []byte) {
+	prog := interp.program
+	if prog == nil {
+		return
+	}
+	prog.sourcesMu.Lock()
+	defer prog.sourcesMu.Unlock()
+	if prog.sources == nil {
+		prog.sources = make(map[string][]byte)
 	}
+	prog.sources[filename] = source
 }
 
 // Helper functions for better error handling and debugging
@@ -479,6 +479,130 @@ func TestInterpreterClosures(t *testing.T) {
 	}
 }
 
+func TestLexicalClosures(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "counter",
+			program: `
+			fun make_counter():
+				count = 0
+				return fun():
+					nonlocal count
+					count += 1
+					return count
+				end
+			end
+			c1 = make_counter()
+			c2 = make_counter()
+			print(c1(), c1(), c2(), c1())
+			`,
+			expected: "1 2 1 3\n",
+		},
+		{
+			name: "nested_closures",
+			program: `
+			fun make_adder(a):
+				return fun(b):
+					return fun(c):
+						return a + b + c
+					end
+				end
+			end
+			add_one = make_adder(1)
+			add_three = add_one(2)
+			print(add_three(10), add_one(20)(30))
+			`,
+			expected: "13 51\n",
+		},
+		{
+			name: "closure_does_not_see_caller_locals",
+			program: `
+			x = "global"
+			fun make_reader():
+				return fun():
+					return x
+				end
+			end
+			fun caller():
+				x = "caller"
+				return make_reader()()
+			end
+			print(caller())
+			`,
+			expected: "global\n",
+		},
+		{
+			name: "closures_in_loop",
+			program: `
+			fun make_multiplier(n):
+				return fun(x):
+					return x * n
+				end
+			end
+			fun build():
+				fns = []
+				for (i in [1, 2, 3]):
+					append(fns, make_multiplier(i))
+				end
+				last = []
+				for (i in range(3)):
+					append(last, fun():
+						return i
+					end)
+				end
+				return [fns, last]
+			end
+			result = build()
+			print(result[0][0](10), result[0][1](10), result[0][2](10))
+			// Closures created directly in the loop share the loop variable
+			print(result[1][0](), result[1][2]())
+			`,
+			expected: "10 20 30\n2 2\n",
+		},
+		{
+			name: "recursive_closures",
+			program: `
+			fun outer(n):
+				fun fact(k):
+					if (k <= 1) then:
+						return 1
+					end
+					return k * fact(k - 1)
+				end
+				fib = fun(k):
+					return k < 2 ? k : fib(k - 1) + fib(k - 2)
+				end
+				return [fact(n), fib(n)]
+			end
+			print(outer(6))
+			`,
+			expected: "[720, 8]\n",
+		},
+		{
+			name: "assignment_in_function_does_not_rebind_globals",
+			program: `
+			total = 1
+			fun f():
+				total = 100
+				return total
+			end
+			print(f(), total)
+			`,
+			expected: "100 1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
 func TestInterpreterRecursion(t *testing.T) {
 	program := `
 	fun factorial(n):
@@ -0,0 +1,72 @@
+package interpreter
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// limitCheckInterval is the number of operations between checks of the
+// wall-clock deadline and the context, which are too costly to check on
+// every operation.
+const limitCheckInterval = 1000
+
+// limits holds the resource limits of a running program.
+type limits struct {
+	ctx          context.Context
+	maxOps       int
+	maxCallDepth int
+	maxRecursion int
+	timeout      time.Duration
+	deadline     time.Time
+	// nextCheck is the value of Stats.Ops at which checkLimits runs next
+	nextCheck int
+}
+
+func newLimits(config *Config) limits {
+	l := limits{
+		ctx:          config.Context,
+		maxOps:       config.MaxOps,
+		maxCallDepth: config.MaxCallDepth,
+		maxRecursion: config.MaxRecursionDepth,
+		timeout:      config.Timeout,
+	}
+	if l.maxRecursion <= 0 {
+		l.maxRecursion = DefaultMaxRecursionDepth
+	}
+	if l.timeout > 0 {
+		l.deadline = time.Now().Add(l.timeout)
+	}
+	l.schedule(0)
+	return l
+}
+
+// schedule sets the op count at which the limits are checked next.
+func (l *limits) schedule(ops int) {
+	l.nextCheck = math.MaxInt
+	if l.ctx != nil || !l.deadline.IsZero() {
+		l.nextCheck = ops + limitCheckInterval
+	}
+	if l.maxOps > 0 && l.maxOps < l.nextCheck {
+		l.nextCheck = l.maxOps + 1
+	}
+}
+
+// checkLimits stops execution with a LimitError if the program has run out
+// of operations or time, or its context has been cancelled. The evaluation
+// loops call it whenever Stats.Ops reaches limits.nextCheck.
+func (interp *interpreter) checkLimits(pos Position) {
+	l := &interp.limits
+	if l.maxOps > 0 && interp.stats.Ops > l.maxOps {
+		panic(limitError(pos, nil, "operation limit of %d exceeded", l.maxOps))
+	}
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		panic(limitError(pos, context.DeadlineExceeded, "execution timed out after %v", l.timeout))
+	}
+	if l.ctx != nil {
+		if err := l.ctx.Err(); err != nil {
+			panic(limitError(pos, err, "execution cancelled: %v", err))
+		}
+	}
+	l.schedule(interp.stats.Ops)
+}
@@ -0,0 +1,127 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// executeWithLimits runs a program on both engines with the limits set in
+// config and returns the error of each run.
+func executeWithLimits(t *testing.T, program string, config Config) map[string]error {
+	t.Helper()
+	prog, err := ParseProgram([]byte(program))
+	if err != nil {
+		t.Fatalf("Failed to parse program: %v", err)
+	}
+	errs := make(map[string]error)
+	for _, e := range benchmarkEngines {
+		config := config
+		config.Stdout = io.Discard
+		config.Engine = e.engine
+		_, errs[e.name] = Execute(prog, &config)
+	}
+	return errs
+}
+
+func TestExecutionLimits(t *testing.T) {
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		program string
+		config  Config
+		message string
+		cause   error
+	}{
+		{
+			name:    "max_ops",
+			program: "while (true):\nend",
+			config:  Config{MaxOps: 500},
+			message: "operation limit of 500 exceeded",
+		},
+		{
+			name:    "timeout",
+			program: "x = 0\nwhile (true):\n    x += 1\nend",
+			config:  Config{Timeout: 20 * time.Millisecond},
+			message: "execution timed out after 20ms",
+			cause:   context.DeadlineExceeded,
+		},
+		{
+			name:    "cancelled_context",
+			program: "while (true):\nend",
+			config:  Config{Context: expired},
+			message: "execution cancelled: context canceled",
+			cause:   context.Canceled,
+		},
+		{
+			name:    "max_call_depth",
+			program: "fun down(n):\n    return down(n + 1)\nend\ndown(0)",
+			config:  Config{MaxCallDepth: 50},
+			message: "limit error at 2:12: maximum call depth of 50 exceeded",
+		},
+		{
+			name: "not_caught_by_try",
+			program: `while (true):
+    try:
+        x = 1
+    catch (e):
+        print("caught", e)
+    end
+end`,
+			config:  Config{MaxOps: 1000},
+			message: "operation limit of 1000 exceeded",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for engine, err := range executeWithLimits(t, test.program, test.config) {
+				var limitErr LimitError
+				if !errors.As(err, &limitErr) {
+					t.Fatalf("Expected LimitError on %s, got %v", engine, err)
+				}
+				if !strings.Contains(err.Error(), test.message) {
+					t.Errorf("Expected error containing %q on %s, got %v", test.message, engine, err)
+				}
+				if test.cause != nil && !errors.Is(err, test.cause) {
+					t.Errorf("Expected error on %s to wrap %v", engine, test.cause)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutionWithinLimits(t *testing.T) {
+	program := `
+	fun fib(n):
+		return n < 2 ? n : fib(n - 1) + fib(n - 2)
+	end
+	print(fib(10))
+	`
+	config := Config{
+		Context:      context.Background(),
+		MaxOps:       100000,
+		MaxCallDepth: 20,
+		Timeout:      time.Minute,
+	}
+	for engine, err := range executeWithLimits(t, program, config) {
+		if err != nil {
+			t.Errorf("Unexpected error on %s: %v", engine, err)
+		}
+	}
+}
+
+func TestRunProgramWithOptionsLimits(t *testing.T) {
+	success, output := RunProgramWithOptions("print(1)\nwhile (true):\nend", &RunProgramOptions{MaxOps: 100})
+	if success {
+		t.Fatalf("Expected execution to fail, got: %s", output)
+	}
+	if !strings.Contains(output, "operation limit of 100 exceeded") {
+		t.Errorf("Expected limit error in output, got: %s", output)
+	}
+}
@@ -212,3 +212,78 @@ func TestLoopControlErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestTwoVariableForLoops(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "array_indexes",
+			program: `
+				for (i, fruit in ["apple", "banana"]):
+					print(i, fruit)
+				end`,
+			expected: "0 apple\n1 banana\n",
+		},
+		{
+			name: "string_indexes",
+			program: `
+				for (i, ch in "héj"):
+					print(i, ch)
+				end`,
+			expected: "0 h\n1 é\n2 j\n",
+		},
+		{
+			name: "object_keys_and_values",
+			program: `
+				scores = {alice: 3, bob: 5}
+				total = 0
+				seen = []
+				for (name, score in scores):
+					total += score
+					append(seen, name)
+				end
+				print(total, len(seen), "alice" in seen, "bob" in seen)`,
+			expected: "8 2 true true\n",
+		},
+		{
+			name: "destructured_values",
+			program: `
+				for (i, [x, y] in [[1, 2], [3, 4]]):
+					print(i, x * y)
+				end`,
+			expected: "0 2\n1 12\n",
+		},
+		{
+			name: "break_and_continue",
+			program: `
+				fun find(xs, wanted):
+					for (i, x in xs):
+						if (x == wanted) then:
+							return i
+						end
+					end
+					return -1
+				end
+				for (i, x in [5, 6, 7, 8]):
+					if (i == 1) then:
+						continue
+					end
+					if (x == 8) then:
+						break
+					end
+					print(i, x)
+				end
+				print(find(["a", "b"], "b"), find([], 1))`,
+			expected: "0 5\n2 7\n1 -1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package interpreter
+
+import (
+	"fmt"
+)
+
+// patternTypes holds the type names a match pattern can test for, as
+// returned by typeof().
+var patternTypes = map[string]bool{
+	"nullable": true,
+	"boolean":  true,
+	"integer":  true,
+	"float":    true,
+	"string":   true,
+	"array":    true,
+	"object":   true,
+	"function": true,
+}
+
+// matchPattern reports whether value matches pattern, storing the values
+// captured by the pattern in the slots of env, the environment of the arm.
+// A nil pattern is the default arm, which matches any value.
+func matchPattern(pattern Pattern, value Value, env *environment) bool {
+	switch p := pattern.(type) {
+	case nil:
+		return true
+	case *LiteralPattern:
+		return evalEqual(p.pos, value, p.Value).(bool)
+	case *CapturePattern:
+		p.bind(value, env)
+		return true
+	case *TypePattern:
+		if typeName(value) != p.Type {
+			return false
+		}
+		if p.Capture != nil {
+			p.Capture.bind(value, env)
+		}
+		return true
+	case *ArrayPattern:
+		array, ok := value.(*[]Value)
+		if !ok {
+			return false
+		}
+		values := *array
+		if len(values) < len(p.Elements) || p.Rest == nil && len(values) != len(p.Elements) {
+			return false
+		}
+		for i, element := range p.Elements {
+			if !matchPattern(element, values[i], env) {
+				return false
+			}
+		}
+		if p.Rest != nil {
+			rest := append([]Value{}, values[len(p.Elements):]...)
+			p.Rest.bind(&rest, env)
+		}
+		return true
+	case *ObjectPattern:
+		var fields *Object
+		switch v := value.(type) {
+		case *Object:
+			fields = v
+		case *errorObject:
+			fields = v.fields
+		default:
+			return false
+		}
+		for i, key := range p.Keys {
+			v, ok := fields.Get(key)
+			if !ok || !matchPattern(p.Values[i], v, env) {
+				return false
+			}
+		}
+		return true
+	default:
+		// Parser should never get us here
+		panic(fmt.Sprintf("unexpected pattern type %T", pattern))
+	}
+}
+
+// bind stores a captured value in its slot, unless the pattern is _.
+func (p *CapturePattern) bind(value Value, env *environment) {
+	if p.Name != "_" {
+		env.slots[p.slot] = value
+	}
+}
+
+// executeMatch runs the first arm of a match statement whose pattern matches
+// the subject and whose guard holds. An arm with captures runs in a new
+// environment holding them.
+func (interp *interpreter) executeMatch(s *Match) completion {
+	subject := interp.evaluate(s.Subject)
+	env := interp.env
+	for i := range s.Cases {
+		arm := &s.Cases[i]
+		if arm.scope != nil {
+			interp.env = newEnvironment(arm.scope, env)
+		}
+		if matchPattern(arm.Pattern, subject, interp.env) && interp.matchGuard(arm) {
+			result := interp.executeBlock(arm.Body)
+			interp.env = env
+			return result
+		}
+		interp.env = env
+	}
+	return completion{}
+}
+
+// matchGuard evaluates the guard of a case arm, if it has one.
+func (interp *interpreter) matchGuard(arm *MatchCase) bool {
+	if arm.Guard == nil {
+		return true
+	}
+	cond := interp.evaluate(arm.Guard)
+	c, ok := cond.(bool)
+	if !ok {
+		panic(typeError(arm.Guard.Position(), "match guard must be bool, got %s", typeName(cond)))
+	}
+	return c
+}
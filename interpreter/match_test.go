@@ -0,0 +1,236 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchStatement(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "literals_and_types",
+			program: `
+			for (v in [1, -2, 2.5, "a", true, null, [], {}, print]):
+				match (v):
+					case 1:
+						print("one")
+					case -2:
+						print("minus two")
+					case "a":
+						print("letter")
+					case float f:
+						print("float", f)
+					case boolean:
+						print("boolean")
+					case nullable:
+						print("null")
+					case function:
+						print("function")
+					default:
+						print("default", typeof(v))
+				end
+			end`,
+			expected: "one\nminus two\nfloat 2.5\nletter\nboolean\nnull\ndefault array\ndefault object\nfunction\n",
+		},
+		{
+			name: "array_shapes",
+			program: `
+			for (v in [[], [1], [1, 2, 3], [[4, 5], 6], "abc"]):
+				match (v):
+					case []:
+						print("empty")
+					case [x]:
+						print("one", x)
+					case [[a, b], c]:
+						print("nested", a + b + c)
+					case [first, ...rest]:
+						print("first", first, "rest", rest)
+					case _:
+						print("not an array")
+				end
+			end`,
+			expected: "empty\none 1\nfirst 1 rest [2, 3]\nnested 15\nnot an array\n",
+		},
+		{
+			name: "object_shapes",
+			program: `
+			people = [{name: "Ann", age: 30}, {name: "Bo", age: 7}, {name: "Cy"}, {"full name": "D"}]
+			for (p in people):
+				match (p):
+					case {name: n, age: a} if a >= 18:
+						print(n, "adult")
+					case {name, age}:
+						print(name, "child", age)
+					case {name}:
+						print(name, "unknown age")
+					case {"full name": n}:
+						print("full", n)
+				end
+			end`,
+			expected: "Ann adult\nBo child 7\nCy unknown age\nfull D\n",
+		},
+		{
+			name: "guards",
+			program: `
+			for (n in [-5, 0, 5]):
+				match (n):
+					case x if x > 0:
+						print("positive", x)
+					case x if x < 0:
+						print("negative", x)
+					default:
+						print("zero")
+				end
+			end`,
+			expected: "negative -5\nzero\npositive 5\n",
+		},
+		{
+			name: "no_arm_matches",
+			program: `
+			match (3) {
+				case 1 {
+					print("one")
+				}
+				case 2 {
+					print("two")
+				}
+			}
+			print("done")`,
+			expected: "done\n",
+		},
+		{
+			name: "captures_are_scoped_to_the_arm",
+			program: `
+			x = "global"
+			match (1):
+				case x:
+					print("arm", x)
+			end
+			print(x)
+			fun f():
+				y = "local"
+				match ([2]):
+					case [y]:
+						y = y * 10
+						print("arm", y)
+				end
+				return y
+			end
+			print(f())`,
+			expected: "arm 1\nglobal\narm 20\nlocal\n",
+		},
+		{
+			name: "assignments_in_arms_are_visible_after_them",
+			program: `
+			fun f(v):
+				match (v):
+					case [a, b]:
+						total = a + b
+					default:
+						total = 0
+				end
+				return total
+			end
+			print(f([1, 2]), f(null))`,
+			expected: "3 0\n",
+		},
+		{
+			name: "closures_capture_arm_variables",
+			program: `
+			fs = []
+			for (i in range(3)):
+				match ({value: i}):
+					case {value}:
+						append(fs, fun(): return value end)
+				end
+			end
+			print(fs[0](), fs[1](), fs[2]())`,
+			expected: "0 1 2\n",
+		},
+		{
+			name: "control_flow_out_of_arms",
+			program: `
+			fun first_string(xs):
+				for (x in xs):
+					match (x):
+						case string s:
+							return s
+					end
+				end
+			end
+			seen = []
+			for (x in [1, 2, 3, 4, 5]):
+				match (x):
+					case 2:
+						continue
+					case n if n > 3:
+						break
+					case n:
+						try:
+							append(seen, n)
+						catch (e):
+							print(e)
+						finally:
+							append(seen, "f")
+						end
+				end
+			end
+			print(first_string([1, "a", "b"]), seen)`,
+			expected: "a [1, \"f\", 3, \"f\"]\n",
+		},
+		{
+			name: "caught_errors",
+			program: `
+			try:
+				x = [][1]
+			catch (e):
+				match (e):
+					case {type: "TypeError"}:
+						print("type")
+					case {type: "ValueError", message: m}:
+						print("value", m)
+				end
+			end`,
+			expected: "value subscript 1 out of range\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestMatchGuardMustBeBool(t *testing.T) {
+	program := "match (1):\n    case x if x:\n        print(x)\nend"
+	expectErrorOnAllEngines(t, program, "type error at 2:15: match guard must be bool, got integer")
+}
+
+func TestMatchParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		errorMsg string
+	}{
+		{"match (x): default: print(1) case 1: print(2) end", "parse error at 1:30: the default arm must be the last arm of a match statement"},
+		{"match (x): print(1) end", "parse error at 1:12: expected 'case' or 'default' in match statement, got name"},
+		{"match (x): case [...a, b]: print(a) end", "parse error at 1:24: rest pattern '...' must be the last element of an array pattern"},
+		{"match (x): case 1 + 2: print(1) end", "parse error at 1:19: expected ':' to start block"},
+		{"match (x): case -a: print(1) end", "parse error at 1:18: expected a number after '-' in pattern, got name"},
+		{"match (x): case {1: a}: print(a) end", "parse error at 1:18: expected property name in object pattern, got int"},
+		{"match (x) case 1: print(1) end", "parse error at 1:11: expected ':' after 'match (...)' to start block, got case"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseProgram([]byte(test.input))
+		if err == nil {
+			t.Errorf("Expected error parsing %q", test.input)
+		} else if !strings.Contains(err.Error(), test.errorMsg) {
+			t.Errorf("Expected error containing %q parsing %q, got %q", test.errorMsg, test.input, err.Error())
+		}
+	}
+}
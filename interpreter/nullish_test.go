@@ -0,0 +1,171 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOptionalChainingAndNullish(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "optional_chaining",
+			program: `
+			config = {server: {ports: [80, 443], describe: fun(): return "web" end}}
+			missing = null
+			print(config?.server?.ports?.[1], config.server?.describe?.())
+			print(missing?.server, missing?.[0], missing?.(1))`,
+			expected: "443 web\nnull null null\n",
+		},
+		{
+			name: "missing_keys",
+			program: `
+			config = {name: "app", debug: null}
+			print(config?.port, config?.["name"], config?.debug, config?.server?.port)
+			print(config?.port ?? 8080, config?.server?.port ?? 80)
+			try:
+				throw {code: 7}
+			catch (e):
+				print(e?.code, e?.missing)
+			end`,
+			expected: "null app null null\n8080 80\n7 null\n",
+		},
+		{
+			name: "short_circuits_whole_chain",
+			program: `
+			fun noisy(x):
+				print("evaluated")
+				return x
+			end
+			missing = null
+			print(missing?.a.b[noisy(0)](noisy(1)).c)`,
+			expected: "null\n",
+		},
+		{
+			name: "nullish_only_falls_back_on_null",
+			program: `
+			fun fallback():
+				print("fallback")
+				return "default"
+			end
+			print(null ?? "default", 0 ?? 1, false ?? true, "" ?? "x", [] ?? [1])
+			print(1 ?? fallback(), null ?? fallback())
+			print(null ?? null ?? 3, null?.x ?? "none")`,
+			expected: "default 0 false  []\nfallback\n1 default\n3 none\n",
+		},
+		{
+			name: "precedence",
+			program: `
+			n = null
+			print(n ?? 2 * 3, 1 + (n ?? 1), n ?? 1 < 2, n ?? 1 | 2)`,
+			expected: "6 2 true 3\n",
+		},
+		{
+			name: "nullish_assignment",
+			program: `
+			x = null
+			x ??= 5
+			x ??= 7
+			print(x)
+			opts = {retries: null, verbose: false}
+			opts.retries ??= 3
+			opts.verbose ??= true
+			print(opts)
+			fun init():
+				print("init")
+				return [0]
+			end
+			items = [null, 1]
+			items[0] ??= init()
+			items[-1] ??= init()
+			print(items)`,
+			expected: "5\n{\"retries\": 3, \"verbose\": false}\ninit\n[[0], 1]\n",
+		},
+		{
+			name: "nullish_assignment_inserts_missing_keys",
+			program: `
+			config = {host: "localhost"}
+			config.port ??= 80
+			config.port ??= 8080
+			config["host"] ??= "example.com"
+			print(config)`,
+			expected: "{\"host\": \"localhost\", \"port\": 80}\n",
+		},
+		{
+			name: "nullish_default_parameter",
+			program: `
+			fun greet(name):
+				name ??= "stranger"
+				return "hello " + name
+			end
+			print(greet(null), greet("ann"))`,
+			expected: "hello stranger hello ann\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestOptionalChainingErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"not_callable", "x = 1?.()", "type error at 1:5: can't call non-function type integer"},
+		{"not_subscriptable", "x = 1?.a", "type error at 1:8: can only subscript string, array, or object"},
+		{"unset_variable", "fun f():\nx ??= 1\nend\nf()", "name error at 2:7: name \"x\" not found"},
+		{"missing_key_without_optional", "x = {a: {}}?.a.b", "value error at 1:16: key not found: \"b\""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, test.program, test.message)
+		})
+	}
+}
+
+func TestParseOptionalChainingAndNullish(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a?.b", "a?.[\"b\"]"},
+		{"a?.[i]?.(x).c", "a?.[i]?.(x)[\"c\"]"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b + c", "(a ?? (b + c))"},
+		{"a ?? b == c", "((a ?? b) == c)"},
+		{"c ?.5 : 1", "(c ? 0.5 : 1)"},
+	}
+
+	for _, test := range tests {
+		expr, err := ParseExpression([]byte(test.input))
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", test.input, err)
+		}
+		if expr.String() != test.expected {
+			t.Errorf("Expected %q for %q, got %q", test.expected, test.input, expr.String())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		message string
+	}{
+		{"a?.b = 1", "parse error at 1:6: invalid assignment target"},
+		{"a?.)", "parse error at 1:4: expected name, '[' or '(' after ?., got )"},
+	}
+	for _, test := range errors {
+		_, err := ParseProgram([]byte(test.input))
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected error containing %q for %q, got %v", test.message, test.input, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package interpreter
+
+import (
+	"sort"
+)
+
+// Object is the value of an object: a map from string keys to values that
+// remembers the order its keys were first set in. Iterating over an object,
+// printing it and converting it to a string all follow that order.
+type Object struct {
+	keys   []string
+	values map[string]Value
+}
+
+// NewObject returns an empty object.
+func NewObject() *Object {
+	return &Object{values: make(map[string]Value)}
+}
+
+// Get returns the value of a key and whether the object has the key.
+func (o *Object) Get(key string) (Value, bool) {
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// Set sets the value of a key. A new key goes after the existing ones; an
+// existing key keeps its place.
+func (o *Object) Set(key string, value Value) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// Len returns the number of keys in the object.
+func (o *Object) Len() int {
+	return len(o.keys)
+}
+
+// Keys returns a copy of the keys of the object, in insertion order.
+func (o *Object) Keys() []string {
+	return append([]string{}, o.keys...)
+}
+
+// spreadObject sets the keys of an object spread into an object literal,
+// in order, in obj.
+func spreadObject(pos Position, obj *Object, value Value) {
+	if e, ok := value.(*errorObject); ok {
+		value = e.fields
+	}
+	spread, ok := value.(*Object)
+	if !ok {
+		panic(typeError(pos, "expected object to spread, got %s", typeName(value)))
+	}
+	for _, key := range spread.keys {
+		obj.Set(key, spread.values[key])
+	}
+}
+
+// importValue converts the plain Go maps in a value passed in by an embedder,
+// like the values of Config.Vars, to objects. Maps have no order, so their
+// keys are added in sorted order. Arrays are copied, so the caller's slices
+// aren't changed.
+func importValue(value Value) Value {
+	switch v := value.(type) {
+	case map[string]Value:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		obj := NewObject()
+		for _, k := range keys {
+			obj.Set(k, importValue(v[k]))
+		}
+		return obj
+	case *[]Value:
+		values := make([]Value, len(*v))
+		for i, element := range *v {
+			values[i] = importValue(element)
+		}
+		return &values
+	}
+	return value
+}
@@ -0,0 +1,124 @@
+package interpreter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestObjectsKeepInsertionOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "printing",
+			program: `
+			obj = {zebra: 1, apple: 2, mango: 3}
+			obj.banana = 4
+			obj.zebra = 5
+			print(obj, len(obj))
+			print(str({b: {d: 1, c: 2}, a: []}))`,
+			expected: "{\"zebra\": 5, \"apple\": 2, \"mango\": 3, \"banana\": 4} 4\n" +
+				"{\"b\": {\"d\": 1, \"c\": 2}, \"a\": []}\n",
+		},
+		{
+			name: "iteration",
+			program: `
+			obj = {c: 1, a: 2, b: 3}
+			for (key in obj):
+				print(key)
+			end
+			for (key, value in obj):
+				print(key, value)
+			end`,
+			expected: "c\na\nb\nc 1\na 2\nb 3\n",
+		},
+		{
+			name: "merging",
+			program: `
+			print({b: 1, a: 2} + {c: 3, b: 4})
+			x = {y: 1}
+			x += {w: 2}
+			print(x)`,
+			expected: "{\"b\": 4, \"a\": 2, \"c\": 3}\n{\"y\": 1, \"w\": 2}\n",
+		},
+		{
+			name: "equality_ignores_order",
+			program: `
+			print({a: 1, b: 2} == {b: 2, a: 1}, {a: 1} == {a: 1, b: 2})`,
+			expected: "true false\n",
+		},
+		{
+			name: "thrown_objects",
+			program: `
+			try:
+				throw {message: "oops", code: 7}
+			catch (e):
+				for (key in e):
+					print(key)
+				end
+			end`,
+			expected: "message\ncode\nvalue\ntype\nline\ncolumn\nfile\nstack\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestConfigVarsAcceptPlainMaps(t *testing.T) {
+	prog, err := ParseProgram([]byte(`
+	print(settings, settings.nested.b, len(items[0]))
+	settings.extra = true
+	print(settings)`))
+	if err != nil {
+		t.Fatalf("Failed to parse program: %v", err)
+	}
+	for _, engine := range []Engine{EngineVM, EngineTreeWalker} {
+		var buf bytes.Buffer
+		items := &[]Value{map[string]Value{"x": 1}}
+		config := &Config{
+			Stdout: &buf,
+			Engine: engine,
+			Vars: map[string]Value{
+				"settings": map[string]Value{"name": "app", "debug": false, "nested": map[string]Value{"b": 2, "a": 1}},
+				"items":    items,
+			},
+		}
+		if _, err := Execute(prog, config); err != nil {
+			t.Fatalf("Failed to execute program on engine %d: %v", engine, err)
+		}
+		expected := "{\"debug\": false, \"name\": \"app\", \"nested\": {\"a\": 1, \"b\": 2}} 2 1\n" +
+			"{\"debug\": false, \"name\": \"app\", \"nested\": {\"a\": 1, \"b\": 2}, \"extra\": true}\n"
+		if buf.String() != expected {
+			t.Errorf("Expected %q on engine %d, got %q", expected, engine, buf.String())
+		}
+		if _, ok := (*items)[0].(map[string]Value); !ok {
+			t.Errorf("Expected the caller's array to be left unchanged on engine %d, got %v", engine, (*items)[0])
+		}
+	}
+}
+
+func TestObjectAPI(t *testing.T) {
+	obj := NewObject()
+	obj.Set("b", 1)
+	obj.Set("a", 2)
+	obj.Set("b", 3)
+	if obj.Len() != 2 {
+		t.Errorf("Expected 2 keys, got %d", obj.Len())
+	}
+	keys := obj.Keys()
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "a" {
+		t.Errorf("Expected keys [b a], got %v", keys)
+	}
+	if v, ok := obj.Get("b"); !ok || v != 3 {
+		t.Errorf("Expected b to be 3, got %v (%v)", v, ok)
+	}
+	if _, ok := obj.Get("c"); ok {
+		t.Errorf("Expected c to be missing")
+	}
+}
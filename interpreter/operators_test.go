@@ -0,0 +1,81 @@
+package interpreter
+
+import (
+	"testing"
+)
+
+func TestBitwiseAndPowerOperators(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "bitwise",
+			program: `
+			print(12 & 10, 12 | 10, 12 ^ 10, ~0, ~-6)
+			print(1 << 62, -16 >> 2, 0xF0 >> 4, 1 << 64)`,
+			expected: "8 14 6 -1 5\n4611686018427387904 -4 15 0\n",
+		},
+		{
+			name: "power",
+			program: `
+			print(2 ** 10, 10 ** 0, 0 ** 0, (-3) ** 3)
+			print(2 ** -2, 9 ** 0.5, 2.5 ** 2)`,
+			expected: "1024 1 1 -27\n0.25 3 6.25\n",
+		},
+		{
+			name: "precedence",
+			program: `
+			print(2 ** 3 ** 2, -2 ** 2, 2 ** -1 * 4)
+			print(1 + 1 << 2, 1 << 2 + 1, 6 & 3 << 1, 1 | 6 & 3, 1 ^ 3 | 4)
+			print(5 & 1 == 1, 2 | 1 < 4, ~1 + 1, -1 >> 1 == -1)`,
+			expected: "512 -4 2\n8 8 6 3 6\ntrue true -1 true\n",
+		},
+		{
+			name: "compound_assignment",
+			program: `
+			x = 0b1100
+			x &= 0b1010
+			x |= 0b0001
+			x ^= 0b1111
+			print(x)
+			x <<= 4
+			x >>= 2
+			x **= 2
+			print(x)
+			flags = {mask: 1}
+			flags.mask <<= 3
+			flags["mask"] |= 2
+			print(flags)`,
+			expected: "6\n576\n{\"mask\": 10}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestBitwiseOperatorErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"float_operand", "x = 1.5 & 1", "type error at 1:9: & requires two integers, got float and integer"},
+		{"bool_operand", "x = true | false", "type error at 1:10: | requires two integers, got boolean and boolean"},
+		{"bit_not", "x = ~\"a\"", "type error at 1:5: ~ requires an integer, got string"},
+		{"negative_shift", "x = 1 << -1", "value error at 1:7: << requires a non-negative shift count, got -1"},
+		{"compound", "x = 1\nx >>= \"2\"", "type error at 2:7: >> requires two integers, got integer and string"},
+		{"power_operand", "x = \"a\" ** 2", "type error at 1:9: ** requires"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, test.program, test.message)
+		})
+	}
+}
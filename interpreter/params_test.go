@@ -0,0 +1,186 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultsAndNamedArguments(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "defaults",
+			program: `
+			fun greet(name, greeting = "Hello", punctuation = "!"):
+				return greeting + ", " + name + punctuation
+			end
+			print(greet("Ana"))
+			print(greet("Ana", "Hi"))
+			print(greet("Ana", "Hi", "?"))`,
+			expected: "Hello, Ana!\nHi, Ana!\nHi, Ana?\n",
+		},
+		{
+			name: "evaluated_at_call_time",
+			program: `
+			fun append(x, xs = []):
+				xs = xs + [x]
+				return xs
+			end
+			counter = {calls: 0}
+			fun count():
+				counter.calls += 1
+				return counter.calls
+			end
+			fun f(n = count()):
+				return n
+			end
+			print(append(1), append(2), f(), f(10), f())`,
+			expected: "[1] [2] 1 10 2\n",
+		},
+		{
+			name: "defaults_see_earlier_parameters",
+			program: `
+			fun box(width, height = width, [x, y] = [width / 2, height / 2]):
+				return [width, height, x, y]
+			end
+			print(box(4), box(4, 2), box(4, 2, [0, 0]))`,
+			expected: "[4, 4, 2, 2] [4, 2, 2, 1] [4, 2, 0, 0]\n",
+		},
+		{
+			name: "named_arguments",
+			program: `
+			fun greet(name, greeting = "Hello", punctuation = "!"):
+				return greeting + ", " + name + punctuation
+			end
+			print(greet(name: "Ana"))
+			print(greet("Bo", punctuation: "?"))
+			print(greet(punctuation: ".", name: "Cy", greeting: "Hey"))
+			area = fun(width = 1, height): return width * height end
+			print(area(height: 3), area(2, height: 3))`,
+			expected: "Hello, Ana!\nHello, Bo?\nHey, Cy.\n3 6\n",
+		},
+		{
+			name: "variadic",
+			program: `
+			fun log(level = "info", messages...):
+				print(level, messages)
+			end
+			log()
+			log("warn", "a", "b")
+			log(level: "debug")
+			fun tag(name, values...):
+				return name + str(values)
+			end
+			print(tag(["a"]..., ))`,
+			expected: "info []\nwarn [\"a\", \"b\"]\ndebug []\na[]\n",
+		},
+		{
+			name: "spread_and_named_arguments",
+			program: `
+			fun point(x, y, z = 0, label = "p"):
+				return label + str([x, y, z])
+			end
+			print(point([1, 2]..., label: "q"))
+			print(point([1, 2, 3]..., label: "r"))`,
+			expected: "q[1, 2, 0]\nr[1, 2, 3]\n",
+		},
+		{
+			name: "generators",
+			program: `
+			fun count(from = 0, to = 3, step = 1):
+				n = from
+				while (n < to):
+					yield n
+					n += step
+				end
+			end
+			print(count()...)
+			print(count(step: 2, to: 7)...)`,
+			expected: "0 1 2\n0 2 4 6\n",
+		},
+		{
+			name: "generator_defaults_at_call_time",
+			program: `
+			base = 1
+			fun from_base(start = base, [a, b] = [start, start + 1]):
+				yield start
+				yield a + b
+			end
+			numbers = from_base()
+			base = 100
+			print(numbers...)
+			print(from_base()...)`,
+			expected: "1 3\n100 201\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestArgumentErrors(t *testing.T) {
+	const functions = `
+fun pad(text, width = 8): return text end
+fun log(level, messages...): return level end
+`
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"missing", "pad()", `type error at 4:1: pad() requires 1 to 2 args, got 0: missing argument for parameter "text"`},
+		{"missing_with_named", "pad(width: 2)", `pad() requires 1 to 2 args, got 1: missing argument for parameter "text"`},
+		{"too_many", "pad(1, 2, 3)", "type error at 4:1: pad() requires 1 to 2 args, got 3"},
+		{"variadic_missing", "log()", `log() requires at least 1 arg, got 0: missing argument for parameter "level"`},
+		{"unknown", "pad(\"a\", size: 2)", `type error at 4:1: pad() has no parameter named "size"`},
+		{"multiple_values", "pad(\"a\", text: \"b\")", `pad() got multiple values for parameter "text"`},
+		{"variadic_by_name", "log(messages: [])", `log() can't take variadic parameter "messages" by name`},
+		{"builtin", "print(sep: \"\")", "type error at 4:1: <builtin print> doesn't accept named arguments"},
+		{"anonymous_missing", "(fun(a): return a end)()", `type error at 4:2: <fun>() requires 1 arg, got 0: missing argument for parameter "a"`},
+		{"anonymous_unknown", "(fun(a): return a end)(b: 1)", `<fun>() has no parameter named "b"`},
+		{"generator_default", "fun gen(x = 1 / 0): yield x end\nnumbers = gen()", "value error at 4:15: can't divide by zero"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, functions+test.program, test.message)
+		})
+	}
+}
+
+func TestArgumentParseErrors(t *testing.T) {
+	tests := []struct {
+		program string
+		message string
+	}{
+		{"f(a: 1, 2)", "parse error at 1:9: positional argument can't follow named arguments"},
+		{"f(a: 1, a: 2)", "parse error at 1:9: duplicate named argument a"},
+		{"f(a: xs...)", "parse error at 1:8: named argument a can't be unpacked with '...'"},
+		{"f(xs..., 1)", "parse error at 1:10: variadic argument '...' must be the last positional argument in function call"},
+		{"fun f(a, b = x...): end", "parse error at 1:15: variadic parameter b can't have a default value"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseProgram([]byte(test.program))
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected error containing %q for %q, got %v", test.message, test.program, err)
+		}
+	}
+}
+
+func TestParseDefaultsAndNamedArguments(t *testing.T) {
+	prog, err := ParseProgram([]byte("fun f(a, b = a + 1): return b end\nf(1, b: 2)\nfun g([x, y] = [1, 2]): yield x end"))
+	if err != nil {
+		t.Fatalf("Failed to parse program: %v", err)
+	}
+	expected := "fun f(a, b = (a + 1)) {\n    return b\n}\nf(1, b: 2)\nfun g([x, y] = [1, 2]) {\n    yield x\n}"
+	if prog.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, prog.String())
+	}
+}
@@ -3,6 +3,7 @@ package interpreter
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // Error is the error type returned by ParseExpression and ParseProgram when
@@ -14,7 +15,7 @@ type Error struct {
 }
 
 func (e Error) Error() string {
-	return fmt.Sprintf("parse error at %d:%d: %s", e.Position.Line, e.Position.Column, e.Message)
+	return fmt.Sprintf("parse error at %s: %s", e.Position, e.Message)
 }
 
 type parser struct {
@@ -22,10 +23,15 @@ type parser struct {
 	pos       Position
 	tok       Token
 	val       string
+	newline   bool // Whether the current token starts a new line
+	functions int  // Number of function bodies being parsed
 }
 
 func (p *parser) next() {
+	// The tokenizer is positioned just after the previous token
+	line := p.tokenizer.pos.Line
 	p.pos, p.tok, p.val = p.tokenizer.Next()
+	p.newline = p.pos.Line > line
 	if p.tok == ILLEGAL {
 		p.error("%s", p.val)
 	}
@@ -43,6 +49,14 @@ func (p *parser) expect(tok Token) {
 	p.next()
 }
 
+// peek returns the token after the current one, without consuming either.
+func (p *parser) peek() Token {
+	saved := *p.tokenizer
+	_, tok, _ := p.tokenizer.Next()
+	*p.tokenizer = saved
+	return tok
+}
+
 func (p *parser) matches(operators ...Token) bool {
 	for _, operator := range operators {
 		if p.tok == operator {
@@ -55,7 +69,7 @@ func (p *parser) matches(operators ...Token) bool {
 // program = statement*
 func (p *parser) program() *Program {
 	statements := p.statements(EOF)
-	return &Program{statements}
+	return &Program{Statements: statements}
 }
 
 func (p *parser) statements(end Token) Block {
@@ -66,11 +80,12 @@ func (p *parser) statements(end Token) Block {
 	return statements
 }
 
-// statement = if | while | for | return | break | continue | import | fun | try | assign | expression
+// statement = if | while | for | match | return | throw | yield | nameDeclaration | break | continue | import | fun | try | assign | expression
 // assign    = NAME ASSIGN expression |
 //
 //	call subscript ASSIGN expression |
-//	call dot ASSIGN expression
+//	call dot ASSIGN expression |
+//	(arrayTarget | objectTarget) ASSIGN expression
 func (p *parser) statement() Statement {
 	switch p.tok {
 	case IF:
@@ -79,8 +94,14 @@ func (p *parser) statement() Statement {
 		return p.while()
 	case FOR:
 		return p.for_()
+	case MATCH:
+		return p.match()
 	case RETURN:
 		return p.return_()
+	case THROW:
+		return p.throw_()
+	case YIELD:
+		return p.yield_()
 	case BREAK:
 		return p.break_()
 	case CONTINUE:
@@ -92,10 +113,23 @@ func (p *parser) statement() Statement {
 	case TRY:
 		return p.tryCatch()
 	}
+	if p.declaresNames() {
+		return p.nameDeclaration()
+	}
 	pos := p.pos
+	if p.tok == LBRACKET || p.tok == LBRACE {
+		if target := p.destructuringTarget(); target != nil {
+			if p.tok != ASSIGN {
+				p.error("destructuring assignment only supports '=', got %s", p.tok)
+			}
+			pos = p.pos
+			p.next()
+			value := p.expression()
+			return &Assign{pos, target, value, ASSIGN}
+		}
+	}
 	expr := p.expression()
-	if p.tok == ASSIGN || p.tok == PLUSEQUAL || p.tok == MINUSEQUAL ||
-	   p.tok == TIMESEQUAL || p.tok == DIVIDEEQUAL || p.tok == MODULOEQUAL {
+	if p.matches(assignOperators...) {
 		operator := p.tok
 		pos = p.pos
 		switch expr.(type) {
@@ -110,6 +144,123 @@ func (p *parser) statement() Statement {
 	return &ExpressionStatement{pos, expr}
 }
 
+// assignOperators are the tokens of the assignment operators, = and the
+// compound assignment operators like +=.
+var assignOperators = []Token{
+	ASSIGN, PLUSEQUAL, MINUSEQUAL, TIMESEQUAL, DIVIDEEQUAL, MODULOEQUAL, POWEREQUAL,
+	BITANDEQUAL, BITOREQUAL, BITXOREQUAL, SHLEQUAL, SHREQUAL, NULLISHEQUAL,
+}
+
+// destructuringTarget parses an array or object destructuring target if the
+// statement starts with one followed by an assignment operator. Otherwise it
+// leaves the parser where it was and returns nil, so the statement can be
+// parsed as an expression.
+func (p *parser) destructuringTarget() (target Expression) {
+	saved, savedTokenizer := *p, *p.tokenizer
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(Error); !ok {
+				panic(r)
+			}
+			*p, *p.tokenizer = saved, savedTokenizer
+			target = nil
+		}
+	}()
+	target = p.target()
+	if !p.matches(assignOperators...) {
+		p.error("not an assignment")
+	}
+	return target
+}
+
+// target       = arrayTarget | objectTarget | NAME | call subscript | call dot
+// arrayTarget  = LBRACKET (target COMMA)* (target | ELLIPSIS target)? RBRACKET
+// objectTarget = LBRACE ((NAME | STR) (COLON target)? COMMA?)* RBRACE
+func (p *parser) target() Expression {
+	switch p.tok {
+	case LBRACKET:
+		return p.arrayTarget()
+	case LBRACE:
+		return p.objectTarget()
+	}
+	pos := p.pos
+	target := p.call()
+	switch target.(type) {
+	case *Variable, *Subscript:
+		return target
+	}
+	panic(Error{pos, "invalid destructuring target: only variables, array/object elements and nested patterns can be assigned to"})
+}
+
+func (p *parser) arrayTarget() Expression {
+	pos := p.pos
+	p.expect(LBRACKET)
+	elements := []Expression{}
+	var rest Expression
+	gotComma := true
+	for p.tok != RBRACKET && p.tok != EOF {
+		if !gotComma {
+			p.error("missing comma ',' between destructuring targets")
+		}
+		if rest != nil {
+			p.error("rest target '...' must be the last element of an array pattern")
+		}
+		if p.tok == ELLIPSIS {
+			p.next()
+			rest = p.target()
+		} else {
+			elements = append(elements, p.target())
+		}
+		if p.tok == COMMA {
+			gotComma = true
+			p.next()
+		} else {
+			gotComma = false
+		}
+	}
+	p.expect(RBRACKET)
+	return &ArrayTarget{pos, elements, rest}
+}
+
+func (p *parser) objectTarget() Expression {
+	pos := p.pos
+	p.expect(LBRACE)
+	keys := []string{}
+	values := []Expression{}
+	gotComma := true
+	for p.tok != RBRACE && p.tok != EOF {
+		if !gotComma {
+			p.error("missing comma ',' between destructuring targets")
+		}
+		keyPos, key := p.pos, p.val
+		if p.tok != NAME && p.tok != STR {
+			p.error("expected property name in object pattern, got %s", p.tok)
+		}
+		isName := p.tok == NAME
+		p.next()
+		var value Expression
+		if p.tok == COLON {
+			p.next()
+			value = p.target()
+		} else if isName {
+			// {name} is short for {name: name}
+			value = &Variable{keyPos, key, binding{}}
+		} else {
+			p.error("expected ':' after quoted property name in object pattern, got %s", p.tok)
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+		if p.tok == COMMA {
+			gotComma = true
+			p.next()
+		} else {
+			gotComma = false
+		}
+	}
+	p.expect(RBRACE)
+	return &ObjectTarget{pos, keys, values}
+}
+
 // block = (LBRACE statement* RBRACE) | (COLON statement* END)
 func (p *parser) block() Block {
 	switch p.tok {
@@ -120,13 +271,13 @@ func (p *parser) block() Block {
             return body
         case COLON:
             p.expect(COLON)
-            // We'll collect statements until we hit END, ELSE, or CATCH
+            // We'll collect statements until we hit END, ELSE, CATCH, or FINALLY
             statements := Block{}
-            for p.tok != END && p.tok != ELSE && p.tok != CATCH && p.tok != EOF {
+            for p.tok != END && p.tok != ELSE && p.tok != CATCH && p.tok != FINALLY && p.tok != EOF {
                 statements = append(statements, p.statement())
             }
 
-            // Only expect END if we're not at ELSE or CATCH
+            // Only expect END if we're not at ELSE, CATCH, or FINALLY
             if p.tok == END {
                 p.expect(END)
             }
@@ -179,21 +330,235 @@ func (p *parser) while() Statement {
 	return &While{pos, condition, body}
 }
 
-// for = FOR LPAREN NAME IN expression RPAREN block
+// for     = FOR LPAREN forHead RPAREN block
+// forHead = (NAME COMMA)? forItem IN expression
+// forItem = NAME | arrayTarget | objectTarget
 func (p *parser) for_() Statement {
 	pos := p.pos
 	p.expect(FOR)
 	p.expect(LPAREN) // Require opening parenthesis
-	name := p.val
-	p.expect(NAME)
+	loop := p.forHead(pos)
+	p.expect(RPAREN) // Require closing parenthesis
+	loop.Body = p.block()
+	return loop
+}
+
+// forHead parses the variables and iterable of a for loop or a for clause
+// of a comprehension, and returns a For statement without a body.
+func (p *parser) forHead(pos Position) *For {
+	var key, name string
+	var target Expression
+	if p.tok == NAME {
+		name = p.val
+		p.next()
+		if p.tok == COMMA {
+			// for (key, value in ...)
+			key, name = name, ""
+			p.next()
+		}
+	}
+	if name == "" {
+		if p.tok == LBRACKET || p.tok == LBRACE {
+			target = p.target()
+		} else {
+			name = p.val
+			p.expect(NAME)
+		}
+	}
 	p.expect(IN)
 	iterable := p.expression()
+	return &For{pos, key, name, target, iterable, nil, binding{}, binding{}}
+}
+
+// match = MATCH LPAREN expression RPAREN COLON arm* END |
+//
+//	MATCH LPAREN expression RPAREN LBRACE arm* RBRACE
+//
+// arm   = CASE pattern (IF expression)? armBody |
+//
+//	DEFAULT armBody
+func (p *parser) match() Statement {
+	pos := p.pos
+	p.expect(MATCH)
+	p.expect(LPAREN) // Require opening parenthesis
+	subject := p.expression()
 	p.expect(RPAREN) // Require closing parenthesis
-	body := p.block()
-	return &For{pos, name, iterable, body}
+
+	end := END
+	switch p.tok {
+	case COLON:
+		p.next()
+	case LBRACE:
+		p.next()
+		end = RBRACE
+	default:
+		p.error("expected ':' after 'match (...)' to start block, got %s", p.tok)
+	}
+
+	cases := []MatchCase{}
+	gotDefault := false
+	for p.tok != end && p.tok != EOF {
+		if gotDefault {
+			p.error("the default arm must be the last arm of a match statement")
+		}
+		var arm MatchCase
+		switch p.tok {
+		case CASE:
+			p.next()
+			arm.Pattern = p.pattern()
+			if p.tok == IF {
+				p.next()
+				arm.Guard = p.expression()
+			}
+		case DEFAULT:
+			p.next()
+			gotDefault = true
+		default:
+			p.error("expected 'case' or 'default' in match statement, got %s", p.tok)
+		}
+		arm.Body = p.armBody()
+		cases = append(cases, arm)
+	}
+	p.expect(end)
+	return &Match{pos, subject, cases}
+}
+
+// armBody = block | COLON statement*
+//
+// A colon body runs until the next arm or the end of the match statement.
+func (p *parser) armBody() Block {
+	if p.tok != COLON {
+		return p.block()
+	}
+	p.next()
+	statements := Block{}
+	for !p.matches(CASE, DEFAULT, END, RBRACE, EOF) {
+		statements = append(statements, p.statement())
+	}
+	return statements
+}
+
+// pattern       = INT | FLOAT | STR | TRUE | FALSE | NULL |
+//
+//	MINUS (INT | FLOAT) |
+//	NAME |
+//	TYPE NAME? |
+//	arrayPattern |
+//	objectPattern
+//
+// arrayPattern  = LBRACKET (pattern COMMA)* (pattern | ELLIPSIS NAME)? RBRACKET
+// objectPattern = LBRACE ((NAME | STR) (COLON pattern)? COMMA?)* RBRACE
+func (p *parser) pattern() Pattern {
+	pos := p.pos
+	switch p.tok {
+	case INT, FLOAT, STR, TRUE, FALSE, NULL:
+		literal := p.primary().(*Literal)
+		return &LiteralPattern{pos, literal.Value}
+	case MINUS:
+		p.next()
+		if p.tok != INT && p.tok != FLOAT {
+			p.error("expected a number after '-' in pattern, got %s", p.tok)
+		}
+		literal := p.primary().(*Literal)
+		if n, ok := literal.Value.(int); ok {
+			return &LiteralPattern{pos, -n}
+		}
+		return &LiteralPattern{pos, -literal.Value.(float64)}
+	case NAME:
+		name := p.val
+		p.next()
+		if patternTypes[name] {
+			var capture *CapturePattern
+			if p.tok == NAME {
+				capture = &CapturePattern{p.pos, p.val, 0}
+				p.next()
+			}
+			return &TypePattern{pos, name, capture}
+		}
+		return &CapturePattern{pos, name, 0}
+	case LBRACKET:
+		return p.arrayPattern()
+	case LBRACE:
+		return p.objectPattern()
+	default:
+		p.error("unexpected token %s - expected a pattern (literal, name, type name, '[' or '{')", p.tok)
+		return nil
+	}
+}
+
+func (p *parser) arrayPattern() Pattern {
+	pos := p.pos
+	p.expect(LBRACKET)
+	elements := []Pattern{}
+	var rest *CapturePattern
+	gotComma := true
+	for p.tok != RBRACKET && p.tok != EOF {
+		if !gotComma {
+			p.error("missing comma ',' between array pattern elements")
+		}
+		if rest != nil {
+			p.error("rest pattern '...' must be the last element of an array pattern")
+		}
+		if p.tok == ELLIPSIS {
+			p.next()
+			rest = &CapturePattern{p.pos, p.val, 0}
+			p.expect(NAME)
+		} else {
+			elements = append(elements, p.pattern())
+		}
+		if p.tok == COMMA {
+			gotComma = true
+			p.next()
+		} else {
+			gotComma = false
+		}
+	}
+	p.expect(RBRACKET)
+	return &ArrayPattern{pos, elements, rest}
+}
+
+func (p *parser) objectPattern() Pattern {
+	pos := p.pos
+	p.expect(LBRACE)
+	keys := []string{}
+	values := []Pattern{}
+	gotComma := true
+	for p.tok != RBRACE && p.tok != EOF {
+		if !gotComma {
+			p.error("missing comma ',' between object pattern properties")
+		}
+		keyPos, key := p.pos, p.val
+		if p.tok != NAME && p.tok != STR {
+			p.error("expected property name in object pattern, got %s", p.tok)
+		}
+		isName := p.tok == NAME
+		p.next()
+		var value Pattern
+		if p.tok == COLON {
+			p.next()
+			value = p.pattern()
+		} else if isName {
+			// {name} is short for {name: name}
+			value = &CapturePattern{keyPos, key, 0}
+		} else {
+			p.error("expected ':' after quoted property name in object pattern, got %s", p.tok)
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+		if p.tok == COMMA {
+			gotComma = true
+			p.next()
+		} else {
+			gotComma = false
+		}
+	}
+	p.expect(RBRACE)
+	return &ObjectPattern{pos, keys, values}
 }
 
-// tryCatch = TRY block CATCH LPAREN NAME RPAREN block
+// tryCatch = TRY block CATCH LPAREN NAME RPAREN block [FINALLY block] |
+//
+//	TRY block FINALLY block
 func (p *parser) tryCatch() Statement {
 	pos := p.pos
 	p.expect(TRY)
@@ -207,20 +572,40 @@ func (p *parser) tryCatch() Statement {
             p.expect(RBRACE)
         case COLON:
             p.expect(COLON)
-            tryBlock = p.statements(CATCH)
+            tryBlock = Block{}
+            for p.tok != CATCH && p.tok != FINALLY && p.tok != END && p.tok != EOF {
+                tryBlock = append(tryBlock, p.statement())
+            }
         default:
             p.error("expected ':' after 'try' keyword to start block, got %s", p.tok)
             return nil
 	}
 
-	p.expect(CATCH)
-	p.expect(LPAREN) // Require opening parenthesis
-	errVar := p.val
-	p.expect(NAME)
-	p.expect(RPAREN) // Require closing parenthesis
-	catchBlock := p.block()
+	// The catch block can be left out if there is a finally block
+	var errVar string
+	var catchBlock Block
+	if p.tok != FINALLY {
+		if p.tok != CATCH {
+			p.error("expected catch or finally, but got %s", p.tok)
+		}
+		p.next()
+		p.expect(LPAREN) // Require opening parenthesis
+		errVar = p.val
+		p.expect(NAME)
+		p.expect(RPAREN) // Require closing parenthesis
+		catchBlock = p.block()
+	}
 
-	return &TryCatch{pos, tryBlock, errVar, catchBlock}
+	var finallyBlock Block
+	if p.tok == FINALLY {
+		p.next()
+		finallyBlock = p.block()
+		if finallyBlock == nil {
+			finallyBlock = Block{}
+		}
+	}
+
+	return &TryCatch{pos, tryBlock, errVar, catchBlock, finallyBlock, binding{}}
 }
 
 // return = RETURN expression
@@ -231,6 +616,56 @@ func (p *parser) return_() Statement {
 	return &Return{pos, result}
 }
 
+// throw = THROW expression
+func (p *parser) throw_() Statement {
+	pos := p.pos
+	p.expect(THROW)
+	value := p.expression()
+	return &Throw{pos, value}
+}
+
+// yield = YIELD expression
+func (p *parser) yield_() Statement {
+	pos := p.pos
+	if p.functions == 0 {
+		p.error("yield outside of function")
+	}
+	p.expect(YIELD)
+	value := p.expression()
+	return &Yield{pos, value}
+}
+
+// declaresNames reports whether the statement starts with the contextual
+// keyword "global" or "nonlocal", that is, with one of those names followed
+// by another name on the same line. Anywhere else they are plain names.
+func (p *parser) declaresNames() bool {
+	if p.tok != NAME || (p.val != "global" && p.val != "nonlocal") {
+		return false
+	}
+	saved := *p.tokenizer
+	pos, tok, _ := p.tokenizer.Next()
+	*p.tokenizer = saved
+	return tok == NAME && pos.Line == p.pos.Line
+}
+
+// nameDeclaration = ("global" | "nonlocal") NAME { COMMA NAME }
+func (p *parser) nameDeclaration() Statement {
+	pos := p.pos
+	global := p.val == "global"
+	if p.functions == 0 {
+		p.error("%s outside of function", p.val)
+	}
+	p.next()
+	names := []string{p.val}
+	p.expect(NAME)
+	for p.tok == COMMA {
+		p.next()
+		names = append(names, p.val)
+		p.expect(NAME)
+	}
+	return &NameDeclaration{pos, global, names}
+}
+
 // fun = FUN NAME params block |
 //
 //	FUN params block
@@ -240,34 +675,67 @@ func (p *parser) fun_() Statement {
 	if p.tok == NAME {
 		name := p.val
 		p.next()
-		params, ellipsis := p.params()
-		body := p.block()
-		return &FunctionDefinition{pos, name, params, ellipsis, body}
+		params, defaults, ellipsis, prologue := p.params()
+		body := p.functionBody()
+		return &FunctionDefinition{pos, name, params, defaults, ellipsis, body, prologue, binding{}, nil}
 	} else {
-		params, ellipsis := p.params()
-		body := p.block()
-		expr := &FunctionExpression{pos, params, ellipsis, body}
+		params, defaults, ellipsis, prologue := p.params()
+		body := p.functionBody()
+		expr := &FunctionExpression{pos, params, defaults, ellipsis, body, prologue, nil}
 		return &ExpressionStatement{pos, expr}
 	}
 }
 
 // params = LPAREN RPAREN |
 //
-//	LPAREN NAME (COMMA NAME)* ELLIPSIS? COMMA? RPAREN |
-func (p *parser) params() ([]string, bool) {
+//	LPAREN param (COMMA param)* ELLIPSIS? COMMA? RPAREN |
+//
+// param  = (NAME | arrayTarget | objectTarget) (ASSIGN expression)?
+//
+// A destructuring parameter is named after its pattern and destructured by
+// an assignment in the returned prologue, which runs when the function is
+// called, before its body. The prologue also assigns the default values of
+// the parameters, which are returned in defaults, or nil if no parameter has
+// one.
+func (p *parser) params() (params []string, defaults []Expression, ellipsis bool, prologue Block) {
 	p.expect(LPAREN)
-	params := []string{}
+	params = []string{}
+	prologue = Block{}
 	gotComma := true
-	gotEllipsis := false
-	for p.tok != RPAREN && p.tok != EOF && !gotEllipsis {
+	for p.tok != RPAREN && p.tok != EOF && !ellipsis {
 		if !gotComma {
 			p.error("missing comma ',' between function parameters")
 		}
+		pos := p.pos
 		param := p.val
-		p.expect(NAME)
+		var target Expression
+		if p.tok == LBRACKET || p.tok == LBRACE {
+			target = p.target()
+			param = target.String()
+		} else {
+			p.expect(NAME)
+		}
+		if p.tok == ASSIGN {
+			p.next()
+			value := p.expression()
+			if defaults == nil {
+				defaults = make([]Expression, len(params), len(params)+1)
+			}
+			defaults = append(defaults, value)
+			prologue = append(prologue, &DefaultValue{pos, &Variable{pos, param, binding{}}, value})
+		} else if defaults != nil {
+			defaults = append(defaults, nil)
+		}
+		if target != nil {
+			value := &Variable{target.Position(), param, binding{}}
+			prologue = append(prologue, &Assign{target.Position(), target, value, ASSIGN})
+		}
 		params = append(params, param)
 		if p.tok == ELLIPSIS {
-			gotEllipsis = true
+			if defaults != nil && defaults[len(defaults)-1] != nil {
+				p.error("variadic parameter %s can't have a default value", param)
+			}
+			ellipsis = true
 			p.next()
 		}
 		if p.tok == COMMA {
@@ -277,11 +745,37 @@ func (p *parser) params() ([]string, bool) {
 			gotComma = false
 		}
 	}
-	if p.tok != RPAREN && gotEllipsis {
+	if p.tok != RPAREN && ellipsis {
 		p.error("variadic parameter '...' must be the last parameter in function definition")
 	}
 	p.expect(RPAREN)
-	return params, gotEllipsis
+	return params, defaults, ellipsis, prologue
+}
+
+// interpolation = STRSTART expression (STRMID expression)* STREND
+func (p *parser) interpolation() Expression {
+	pos := p.pos
+	strs := []string{p.val}
+	values := []Expression{}
+	for p.tok != STREND {
+		p.next()
+		values = append(values, p.expression())
+		if p.tok != STRMID && p.tok != STREND {
+			p.error("expected } to end interpolation in string, got %s", p.tok)
+		}
+		strs = append(strs, p.val)
+	}
+	p.next()
+	return &Interpolation{pos, strs, values}
+}
+
+// functionBody parses the block of a function. Yield statements are only
+// allowed in function bodies.
+func (p *parser) functionBody() Block {
+	p.functions++
+	body := p.block()
+	p.functions--
+	return body
 }
 
 func (p *parser) binary(parseFunc func() Expression, operators ...Token) Expression {
@@ -348,9 +842,34 @@ func (p *parser) equality() Expression {
 	return p.binary(p.comparison, EQUAL, NOTEQUAL)
 }
 
-// comparison = addition ((LT | LTE | GT | GTE | IN) addition)*
+// comparison = nullish ((LT | LTE | GT | GTE | IN) nullish)*
 func (p *parser) comparison() Expression {
-	return p.binary(p.addition, LT, LTE, GT, GTE, IN)
+	return p.binary(p.nullish, LT, LTE, GT, GTE, IN)
+}
+
+// nullish = bitOr (NULLISH bitOr)*
+func (p *parser) nullish() Expression {
+	return p.binary(p.bitOr, NULLISH)
+}
+
+// bitOr = bitXor (BITOR bitXor)*
+func (p *parser) bitOr() Expression {
+	return p.binary(p.bitXor, BITOR)
+}
+
+// bitXor = bitAnd (BITXOR bitAnd)*
+func (p *parser) bitXor() Expression {
+	return p.binary(p.bitAnd, BITXOR)
+}
+
+// bitAnd = shift (BITAND shift)*
+func (p *parser) bitAnd() Expression {
+	return p.binary(p.shift, BITAND)
+}
+
+// shift = addition ((SHL | SHR) addition)*
+func (p *parser) shift() Expression {
+	return p.binary(p.addition, SHL, SHR)
 }
 
 // addition = multiply ((PLUS | MINUS) multiply)*
@@ -363,41 +882,93 @@ func (p *parser) multiply() Expression {
 	return p.binary(p.negative, TIMES, DIVIDE, MODULO)
 }
 
-// negative = MINUS negative | call
+// negative = (MINUS | BITNOT) negative | power
 func (p *parser) negative() Expression {
-	if p.tok == MINUS {
+	if p.tok == MINUS || p.tok == BITNOT {
+		op := p.tok
 		pos := p.pos
 		p.next()
 		operand := p.negative()
-		return &Unary{pos, MINUS, operand}
+		return &Unary{pos, op, operand}
+	}
+	return p.power()
+}
+
+// power = call (POWER negative)?
+//
+// The power operator is right-associative and binds tighter than a unary
+// minus on its left: -2 ** 2 is -4.
+func (p *parser) power() Expression {
+	expr := p.call()
+	if p.tok == POWER {
+		pos := p.pos
+		p.next()
+		right := p.negative()
+		expr = &Binary{pos, expr, POWER, right}
 	}
-	return p.call()
+	return expr
 }
 
-// call      = primary (args | subscript | dot)*
+// call      = primary (OPTIONAL? (args | subscript) | dot | OPTIONAL NAME)*
 // args      = LPAREN RPAREN |
 //
-//	LPAREN expression (COMMA expression)* ELLIPSIS? COMMA? RPAREN)
+//	LPAREN expression (COMMA expression)* ELLIPSIS? (COMMA named)* COMMA? RPAREN |
+//	LPAREN named (COMMA named)* COMMA? RPAREN
 //
-// subscript = LBRACKET expression RBRACKET
+// named     = NAME COLON expression
+//
+// subscript = LBRACKET (expression | slice) RBRACKET
 // dot       = DOT NAME
+//
+// A bracket starting a new line begins a new statement, like an array
+// destructuring assignment, rather than a subscript.
 func (p *parser) call() Expression {
 	expr := p.primary()
-	for p.matches(LPAREN, LBRACKET, DOT) {
+	chained := false
+	for p.matches(LPAREN, LBRACKET, DOT, OPTIONAL) && !(p.tok == LBRACKET && p.newline) {
+		pos := p.pos
+		optional := p.tok == OPTIONAL
+		if optional {
+			chained = true
+			p.next()
+			if !p.matches(LPAREN, LBRACKET, NAME) {
+				p.error("expected name, '[' or '(' after ?., got %s", p.tok)
+			}
+		}
 		switch p.tok {
             case LPAREN:
-                pos := p.pos
                 p.next()
                 args := []Expression{}
+                var names []string
                 gotComma := true
                 gotEllipsis := false
-                for p.tok != RPAREN && p.tok != EOF && !gotEllipsis {
+                for p.tok != RPAREN && p.tok != EOF {
+                    named := p.tok == NAME && p.peek() == COLON
+                    if gotEllipsis && !named {
+                        p.error("variadic argument '...' must be the last positional argument in function call")
+                    }
                     if !gotComma {
                         p.error("missing comma ',' between function arguments")
                     }
+                    if named {
+                        name := p.val
+                        for _, other := range names {
+                            if other == name {
+                                p.error("duplicate named argument %s", name)
+                            }
+                        }
+                        p.next()
+                        p.next()
+                        names = append(names, name)
+                    } else if names != nil {
+                        p.error("positional argument can't follow named arguments")
+                    }
                     arg := p.expression()
                     args = append(args, arg)
                     if p.tok == ELLIPSIS {
+                        if named {
+                            p.error("named argument %s can't be unpacked with '...'", names[len(names)-1])
+                        }
                         gotEllipsis = true
                         p.next()
                     }
@@ -408,29 +979,55 @@ func (p *parser) call() Expression {
                         gotComma = false
                     }
                 }
-                if p.tok != RPAREN && gotEllipsis {
-                    p.error("variadic argument '...' must be the last argument in function call")
-                }
                 p.expect(RPAREN)
-                expr = &Call{pos, expr, args, gotEllipsis}
+                expr = &Call{pos, expr, args, names, gotEllipsis, optional}
             case LBRACKET:
-                pos := p.pos
                 p.next()
-                subscript := p.expression()
+                subscript := p.subscript()
                 p.expect(RBRACKET)
-                expr = &Subscript{pos, expr, subscript}
+                expr = &Subscript{pos, expr, subscript, optional}
             default:
-                pos := p.pos
-                p.next()
+                if !optional {
+                    p.next()
+                }
                 subscript := &Literal{p.pos, p.val}
                 p.expect(NAME)
-                expr = &Subscript{pos, expr, subscript}
+                expr = &Subscript{pos, expr, subscript, optional}
             }
 	}
+	if chained {
+		expr = &OptionalChain{expr.Position(), expr}
+	}
 	return expr
 }
 
-// primary = NAME | INT | STR | TRUE | FALSE | NIL | list | map |
+// slice = expression? COLON expression? (COLON expression?)?
+//
+// subscript parses what is between the brackets of a subscript, an index
+// expression or a slice.
+func (p *parser) subscript() Expression {
+	pos := p.pos
+	var start, end, step Expression
+	if p.tok != COLON {
+		start = p.expression()
+		if p.tok != COLON {
+			return start
+		}
+	}
+	p.expect(COLON)
+	if p.tok != COLON && p.tok != RBRACKET {
+		end = p.expression()
+	}
+	if p.tok == COLON {
+		p.next()
+		if p.tok != RBRACKET {
+			step = p.expression()
+		}
+	}
+	return &Slice{pos, start, end, step}
+}
+
+// primary = NAME | INT | FLOAT | STR | TRUE | FALSE | NIL | list | map |
 //
 //	FUNC params block |
 //	LPAREN expression RPAREN
@@ -440,32 +1037,30 @@ func (p *parser) primary() Expression {
             name := p.val
             pos := p.pos
             p.next()
-            return &Variable{pos, name}
+            return &Variable{pos, name, binding{}}
         case INT:
-            val := p.val
-            pos := p.pos
-            p.next()
-            n, err := strconv.Atoi(val)
+            n, err := parseInt(p.val)
             if err != nil {
-                // Tokenizer should never give us this
-                panic(fmt.Sprintf("tokenizer gave INT token that isn't an int: %s", val))
+                p.error("integer literal %s is out of range", p.val)
             }
-            return &Literal{pos, n}
-        case FLOAT:
-            val := p.val
             pos := p.pos
             p.next()
-            n, err := strconv.ParseFloat(val, 64)
+            return &Literal{pos, n}
+        case FLOAT:
+            n, err := strconv.ParseFloat(strings.ReplaceAll(p.val, "_", ""), 64)
             if err != nil {
-                // Tokenizer should never give us this
-                panic(fmt.Sprintf("tokenizer gave FLOAT token that isn't a float: %s", val))
+                p.error("float literal %s is out of range", p.val)
             }
+            pos := p.pos
+            p.next()
             return &Literal{pos, n}
         case STR:
             val := p.val
             pos := p.pos
             p.next()
             return &Literal{pos, val}
+        case STRSTART:
+            return p.interpolation()
         case TRUE:
             pos := p.pos
             p.next()
@@ -485,9 +1080,9 @@ func (p *parser) primary() Expression {
         case FUN:
             pos := p.pos
             p.next()
-            args, ellipsis := p.params()
-            body := p.block()
-            return &FunctionExpression{pos, args, ellipsis, body}
+            args, defaults, ellipsis, prologue := p.params()
+            body := p.functionBody()
+            return &FunctionExpression{pos, args, defaults, ellipsis, body, prologue, nil}
         case LPAREN:
             p.next()
             expr := p.expression()
@@ -499,9 +1094,35 @@ func (p *parser) primary() Expression {
 	}
 }
 
-// list = LBRACKET RBRACKET |
+// parseInt converts the value of an INT token, in decimal or with a 0x, 0o
+// or 0b prefix, to an int. Returns an error if it doesn't fit in an int.
+func parseInt(literal string) (int, error) {
+	digits := strings.ReplaceAll(literal, "_", "")
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+	if base != 10 {
+		digits = digits[2:]
+	}
+	n, err := strconv.ParseInt(digits, base, 0)
+	return int(n), err
+}
+
+// list    = LBRACKET RBRACKET |
+//
+//	LBRACKET element (COMMA element)* COMMA? RBRACKET |
+//	LBRACKET expression comprehension RBRACKET
 //
-//	LBRACKET expression (COMMA expression)* COMMA? RBRACKET
+// element = expression | spread
+// spread  = ELLIPSIS expression
 func (p *parser) list() Expression {
 	pos := p.pos
 	p.expect(LBRACKET)
@@ -511,7 +1132,17 @@ func (p *parser) list() Expression {
 		if !gotComma {
 			p.error("missing comma ',' between array elements")
 		}
-		value := p.expression()
+		var value Expression
+		if p.tok == ELLIPSIS {
+			value = p.spread()
+		} else {
+			value = p.expression()
+			if p.tok == FOR && len(values) == 0 {
+				comprehension := p.comprehension(pos, &Element{value.Position(), nil, value})
+				p.expect(RBRACKET)
+				return comprehension
+			}
+		}
 		values = append(values, value)
 		if p.tok == COMMA {
 			gotComma = true
@@ -524,10 +1155,12 @@ func (p *parser) list() Expression {
 	return &List{pos, values}
 }
 
-// map = LBRACE RBRACE |
+// map  = LBRACE RBRACE |
 //
-//	LBRACE expression COLON expression
-//	       (COMMA expression COLON expression)* COMMA? RBRACE
+//	LBRACE item (COMMA item)* COMMA? RBRACE |
+//	LBRACE expression COLON expression comprehension RBRACE
+//
+// item = expression COLON expression | spread
 func (p *parser) map_() Expression {
 	pos := p.pos
 	p.expect(LBRACE)
@@ -537,10 +1170,24 @@ func (p *parser) map_() Expression {
 		if !gotComma {
 			p.error("missing comma ',' between object properties")
 		}
-		key := p.mapKey()
-		p.expect(COLON)
-		value := p.expression()
-		items = append(items, MapItem{key, value})
+		if p.tok == ELLIPSIS {
+			items = append(items, MapItem{nil, p.spread()})
+		} else {
+			name := p.tok == NAME
+			key := p.mapKey()
+			p.expect(COLON)
+			value := p.expression()
+			if p.tok == FOR && len(items) == 0 {
+				if name {
+					// A bare name key of a comprehension is a variable
+					key = &Variable{key.Position(), key.(*Literal).Value.(string), binding{}}
+				}
+				comprehension := p.comprehension(pos, &Element{key.Position(), key, value})
+				p.expect(RBRACE)
+				return comprehension
+			}
+			items = append(items, MapItem{key, value})
+		}
 		if p.tok == COMMA {
 			gotComma = true
 			p.next()
@@ -552,6 +1199,49 @@ func (p *parser) map_() Expression {
 	return &Map{pos, items}
 }
 
+// comprehension = forClause (forClause | IF expression)*
+// forClause     = FOR (LPAREN forHead RPAREN | forHead)
+//
+// Each clause is nested in the body of the one before it, with the element
+// innermost.
+func (p *parser) comprehension(pos Position, element *Element) Expression {
+	clauses := []Statement{}
+	for p.tok == FOR || p.tok == IF {
+		clausePos := p.pos
+		if p.tok == IF {
+			p.next()
+			clauses = append(clauses, &If{clausePos, p.expression(), nil, nil})
+			continue
+		}
+		p.next()
+		if p.tok == LPAREN {
+			p.next()
+			clauses = append(clauses, p.forHead(clausePos))
+			p.expect(RPAREN)
+		} else {
+			clauses = append(clauses, p.forHead(clausePos))
+		}
+	}
+	var body Statement = element
+	for i := len(clauses) - 1; i >= 0; i-- {
+		switch clause := clauses[i].(type) {
+		case *For:
+			clause.Body = Block{body}
+		case *If:
+			clause.Body = Block{body}
+		}
+		body = clauses[i]
+	}
+	return &Comprehension{pos, element, clauses[0].(*For), nil}
+}
+
+// spread parses a spread element of a list or map literal.
+func (p *parser) spread() Expression {
+	pos := p.pos
+	p.expect(ELLIPSIS)
+	return &Spread{pos, p.expression()}
+}
+
 // mapKey parses a map key, which can be:
 // - A string literal: "key" or 'key'
 // - An identifier: key (converted to string literal)
@@ -592,16 +1282,25 @@ func ParseExpression(input []byte) (e Expression, err error) {
 // a *Program and nil. If there's a syntax error, return nil and a
 // parser.Error value.
 func ParseProgram(input []byte) (prog *Program, err error) {
+	return ParseProgramFile("", input)
+}
+
+// ParseProgramFile parses the program in a named source file, like
+// ParseProgram. The positions in the program and in its errors record the
+// filename.
+func ParseProgramFile(filename string, input []byte) (prog *Program, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			// Convert to parser.Error or re-panic
 			err = r.(Error)
 		}
 	}()
-	t := NewTokenizer(input)
+	t := NewFileTokenizer(filename, input)
 	p := parser{tokenizer: t}
 	p.next()
-	return p.program(), nil
+	prog = p.program()
+	resolveProgram(prog)
+	return prog, nil
 }
 
 // break = BREAK
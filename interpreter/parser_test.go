@@ -45,6 +45,11 @@ func TestParseExpression(t *testing.T) {
 		{"[1, 2, 3]", "List"},
 		{"{\"a\": 1, \"b\": 2}", "Map"},
 		{"fun(x): return x * x end", "FunctionExpression"},
+		{"\"x = ${x}\"", "Interpolation"},
+		{"a ** b", "Binary"},
+		{"a & b | c ^ d", "Binary"},
+		{"a << 2", "Binary"},
+		{"~a", "Unary"},
 	}
 
 	for i, test := range tests {
@@ -223,6 +228,28 @@ func TestParseForStatement(t *testing.T) {
 	}
 }
 
+func TestParseTwoVariableForStatement(t *testing.T) {
+	prog, err := ParseProgram([]byte("for (key, value in obj): print(key, value) end"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	forStmt, ok := prog.Statements[0].(*For)
+	if !ok {
+		t.Fatalf("Expected For statement, got %T", prog.Statements[0])
+	}
+	if forStmt.Key != "key" || forStmt.Name != "value" {
+		t.Errorf("Expected names key and value, got %q and %q", forStmt.Key, forStmt.Name)
+	}
+	if got := forStmt.String(); !strings.HasPrefix(got, "for key, value in obj {") {
+		t.Errorf("Unexpected string form %q", got)
+	}
+
+	if _, err := ParseProgram([]byte("for (a, b, c in obj): end")); err == nil {
+		t.Error("Expected error for three loop variables")
+	}
+}
+
 func TestParseFunctionDefinition(t *testing.T) {
 	input := "fun add(a, b): return a + b end"
 	prog, err := ParseProgram([]byte(input))
@@ -280,6 +307,26 @@ func TestParseTryCatchStatement(t *testing.T) {
 	if len(tryCatch.CatchBlock) != 1 {
 		t.Errorf("Expected 1 catch block statement, got %d", len(tryCatch.CatchBlock))
 	}
+
+	// The catch block can be left out if there is a finally block
+	prog, err = ParseProgram([]byte("try: x = 1/0 finally: print(x) end"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tryCatch = prog.Statements[0].(*TryCatch)
+	if tryCatch.ErrVar != "" || tryCatch.CatchBlock != nil || len(tryCatch.FinallyBlock) != 1 {
+		t.Errorf("Expected try-finally without catch, got %s", tryCatch)
+	}
+
+	_, err = ParseProgram([]byte("try: x = 1 end"))
+	if err == nil || !strings.Contains(err.Error(), "parse error at 1:12: expected catch or finally, but got end") {
+		t.Errorf("Expected missing catch error, got %v", err)
+	}
+
+	_, err = ParseProgram([]byte("try { x = 1 } print(x)"))
+	if err == nil || !strings.Contains(err.Error(), "parse error at 1:15: expected catch or finally, but got name") {
+		t.Errorf("Expected missing catch or finally error, got %v", err)
+	}
 }
 
 func TestParseErrors(t *testing.T) {
@@ -299,15 +346,19 @@ func TestParseErrors(t *testing.T) {
 		{"(x + y", true, "parse error at 1:7: expected ), but got EOF"},                                // Unclosed parenthesis
 		{"[1, 2, 3", true, "parse error at 1:9: expected ], but got EOF"},                              // Unclosed bracket
 		{"\"a\": 1,", true, "parse error at 1:4: unexpected token : - expected a value (number, string, identifier, '(', '[', '{', 'fun', etc.)"},                         // Invalid expression
-		{"try:", true, "parse error at 1:5: expected catch, but got EOF"},                              // Incomplete try block
+		{"try:", true, "parse error at 1:5: expected catch or finally, but got EOF"},                   // Incomplete try block
 		{"try: x = 1 catch:", true, "parse error at 1:17: expected (, but got :"},                      // Missing catch variable
 		{"throw x", false, ""},                                                                         // Complete throw - should not error
 		{"try: x = 1 catch (err) print(err)", true, "parse error at 1:24: expected ':' to start block (after 'then', 'while(...)', 'for(...)', 'fun(...)', or 'try'), got name"}, // Missing ':' in catch block
 		{"try: x = 1 catch (err): print(err) end", false, ""},                                          // Complete try-catch - should not error
 		{"if (x > 5) then: y = 10 end", false, ""},                                                     // Complete if - should not error
 		{"fun add(a, b): return", true, "parse error at 1:22: unexpected token EOF - expected a value (number, string, identifier, '(', '[', '{', 'fun', etc.)"},          // Missing return value
-		{"try: x = 1", true, "parse error at 1:11: expected catch, but got EOF"},                       // Missing catch block
+		{"try: x = 1", true, "parse error at 1:11: expected catch or finally, but got EOF"},            // Missing catch block
 		{"try: x = 1 catch (err): print(err) end", false, ""},                                          // Complete try-catch - should not error
+		{"x = 9223372036854775808", true, "parse error at 1:5: integer literal 9223372036854775808 is out of range"}, // Integer too large
+		{"x = 0xFFFF_FFFF_FFFF_FFFF", true, "parse error at 1:5: integer literal 0xFFFF_FFFF_FFFF_FFFF is out of range"}, // Hex integer too large
+		{"x = 1.5e400", true, "parse error at 1:5: float literal 1.5e400 is out of range"},              // Float too large
+		{"x = 9223372036854775807", false, ""},                                                         // Largest integer - should not error
 	}
 
 	for i, test := range tests {
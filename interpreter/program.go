@@ -2,29 +2,54 @@ package interpreter
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"time"
 )
 
+// sourceOf returns the source code of the file a position is in: source for
+// the main program, or the source of a file imported while prog ran. Returns
+// nil if the source of the file isn't known.
+func sourceOf(prog *Program, source []byte, pos Position) []byte {
+	if pos.File == "" {
+		return source
+	}
+	if prog == nil {
+		return nil
+	}
+	prog.sourcesMu.Lock()
+	defer prog.sourcesMu.Unlock()
+	return prog.sources[pos.File]
+}
+
 // showErrorSource returns a string with the source code and a pointer to the error.
 // It formats the error message with a visual indicator pointing to the exact position of the error.
 //
 // Parameters:
-//   - source: The complete source code as a byte array
+//   - source: The complete source code of the file the error occurred in, or nil if it isn't known
 //   - pos: The position (line and column) where the error occurred
 //   - dividerLen: The length of the divider line to use in the error message
 //
 // Returns:
 //   - string: A formatted error message with visual indication of error location
 func showErrorSource(source []byte, pos Position, dividerLen int) string {
-	errMessage := ""
-	errMessage += divider(dividerLen) + "\n"
+	if source == nil {
+		// We don't have the source of this file
+		return ""
+	}
 
 	// Split source into lines and get the line with the error
 	lines := bytes.Split(source, []byte{'\n'})
+	if pos.Line < 1 || pos.Line > len(lines) || pos.Column < 1 || pos.Column-1 > len(lines[pos.Line-1]) {
+		// The position doesn't belong to this source
+		return ""
+	}
 	errorLine := string(lines[pos.Line-1])
 
+	errMessage := ""
+	errMessage += divider(dividerLen) + "\n"
+
 	// Count tabs to adjust the pointer position correctly
 	numTabs := strings.Count(errorLine[:pos.Column-1], "\t")
 
@@ -56,6 +81,66 @@ func divider(stringLen int) string {
 	return strings.Repeat("-", stringLen)
 }
 
+// formatTraceback returns a Python-style traceback of the function calls
+// that led to a runtime error, outermost first, with the source line of each
+// call. The source line of the innermost frame is left out, since it is shown
+// with the error message, and errors raised outside of any function have no
+// traceback.
+//
+// Parameters:
+//   - err: The runtime error
+//   - prog: The program that raised it, which holds the source of its imports
+//   - source: The source code of the main program
+//   - filename: The name to show for the main program
+//
+// Returns:
+//   - string: The formatted traceback, or an empty string if there is none
+func formatTraceback(err error, prog *Program, source []byte, filename string) string {
+	e, ok := err.(tracedError)
+	if !ok || len(e.Stack()) < 2 {
+		return ""
+	}
+
+	result := "Traceback (most recent call last):\n"
+	stack := e.Stack()
+	for i, frame := range stack {
+		if frame.Position.Line == 0 {
+			// The automatic call of main() has no position in the source
+			continue
+		}
+		result += fmt.Sprintf("  File %q, line %d, column %d, in %s\n",
+			positionFilename(frame.Position, filename), frame.Position.Line, frame.Position.Column, frame.Function)
+		if i < len(stack)-1 {
+			result += showErrorSource(sourceOf(prog, source, frame.Position), frame.Position, 50)
+		}
+	}
+	return result
+}
+
+// positionFilename returns the name of the file a position is in, which is
+// filename for the main program.
+func positionFilename(pos Position, filename string) string {
+	if pos.File == "" {
+		return filename
+	}
+	return pos.File
+}
+
+// formatRuntimeError formats an execution error for RunProgram, with the
+// traceback and the source line where it was raised.
+func formatRuntimeError(err error, prog *Program, source []byte, filename string) string {
+	errorMessage := fmt.Sprintf("%s", err)
+	console := formatTraceback(err, prog, source, filename)
+
+	// If it's an interpreter error with position information, show the source context
+	if e, ok := err.(ErrorInterpreter); ok {
+		console += showErrorSource(sourceOf(prog, source, e.Position()), e.Position(), len(errorMessage))
+	} else if e, ok := err.(Error); ok {
+		console += showErrorSource(source, e.Position, len(errorMessage))
+	}
+	return console + errorMessage
+}
+
 // writerFunc is a function type that writes to a string.
 // It's used to capture output from the interpreter.
 type writerFunc func(string)
@@ -152,19 +237,9 @@ func RunProgram(inputSource string) (bool, string) {
 	startTime := time.Now()
 	stats, err := Execute(prog, config)
 
-	// Handle execution errors
+	// Handle execution errors, showing the traceback and source context
 	if err != nil {
-		errorMessage := fmt.Sprintf("%s", err)
-
-		// If it's an interpreter error with position information, show the source context
-		if e, ok := err.(ErrorInterpreter); ok {
-			console += showErrorSource([]byte(inputSource), e.Position(), len(errorMessage))
-		} else if e, ok := err.(Error); ok {
-			console += showErrorSource([]byte(inputSource), e.Position, len(errorMessage))
-		}
-
-		// Add the error message to the console output
-		console += errorMessage
+		console += formatRuntimeError(err, prog, []byte(inputSource), "<program>")
 		return false, console
 	}
 
@@ -183,7 +258,14 @@ func RunProgram(inputSource string) (bool, string) {
 
 // RunProgramOptions defines options for running a program
 type RunProgramOptions struct {
-	ShowProfiling bool // Whether to show execution profiling information
+	ShowProfiling bool            // Whether to show execution profiling information
+	Filename      string          // Name of the program file shown in tracebacks (optional)
+	Engine        Engine          // Execution engine to run the program on
+	Context       context.Context // Stops execution when cancelled (optional)
+	MaxOps        int             // Maximum number of operations, 0 for no limit
+	MaxCallDepth  int             // Maximum depth of nested user function calls, 0 for no limit
+	MaxRecursion  int             // Recursion depth raising a catchable RuntimeError, 0 for the default
+	Timeout       time.Duration   // Maximum wall-clock execution time, 0 for no limit
 }
 
 // RunProgramWithOptions parses and executes the given program with custom options.
@@ -224,24 +306,26 @@ func RunProgramWithOptions(inputSource string, options *RunProgramOptions) (bool
 			resultProgram += s
 		}),
 	}
+	if options != nil {
+		config.Engine = options.Engine
+		config.Context = options.Context
+		config.MaxOps = options.MaxOps
+		config.MaxCallDepth = options.MaxCallDepth
+		config.MaxRecursionDepth = options.MaxRecursion
+		config.Timeout = options.Timeout
+	}
 
 	// Execute the program and capture output
 	startTime := time.Now()
 	stats, err := Execute(prog, config)
 
-	// Handle execution errors
+	// Handle execution errors, showing the traceback and source context
 	if err != nil {
-		errorMessage := fmt.Sprintf("%s", err)
-
-		// If it's an interpreter error with position information, show the source context
-		if e, ok := err.(ErrorInterpreter); ok {
-			console += showErrorSource([]byte(inputSource), e.Position(), len(errorMessage))
-		} else if e, ok := err.(Error); ok {
-			console += showErrorSource([]byte(inputSource), e.Position, len(errorMessage))
+		filename := "<program>"
+		if options != nil && options.Filename != "" {
+			filename = options.Filename
 		}
-
-		// Add the error message to the console output
-		console += errorMessage
+		console += formatRuntimeError(err, prog, []byte(inputSource), filename)
 		return false, console
 	}
 
@@ -293,28 +377,62 @@ func AnalyzeSyntax(inputSource string) (bool, string) {
 		return false, fmt.Sprintf("Program Validation Error: %s", err)
 	}
 
+	// Report variables that are read but never defined
+	if errs := unresolvedNames(prog); len(errs) > 0 {
+		console := ""
+		for _, err := range errs {
+			errorMessage := fmt.Sprintf("Name Error: %s", err)
+			if e, ok := err.(ErrorInterpreter); ok {
+				console += showErrorSource([]byte(inputSource), e.Position(), len(errorMessage))
+			}
+			console += errorMessage + "\n"
+		}
+		return false, console
+	}
+
 	// If we reach here, syntax is valid
 	return true, "✓ Syntax analysis passed - No syntax errors found\n"
 }
 
 // Enhanced error formatting and reporting functions
 
-// FormatExecutionError formats execution errors with better context
+// FormatExecutionError formats execution errors with better context. Source
+// lines are only shown for errors raised in the main program; use
+// FormatProgramError to show them for files it imported too.
 func FormatExecutionError(err error, source []byte, filename string) string {
+	return FormatProgramError(err, nil, source, filename)
+}
+
+// FormatProgramError formats an error returned by executing prog like
+// FormatExecutionError, with the source lines of the files prog imported
+// shown for errors raised in them.
+func FormatProgramError(err error, prog *Program, source []byte, filename string) string {
 	if err == nil {
 		return ""
 	}
 
+	// Show the calls that led to the error, then the error itself in the
+	// file it was raised in
+	traceback := formatTraceback(err, prog, source, filename)
+	if e, ok := err.(ErrorInterpreter); ok {
+		source = sourceOf(prog, source, e.Position())
+		filename = positionFilename(e.Position(), filename)
+	}
+
 	// Check if it's one of our custom error types with position
 	switch e := err.(type) {
 	case TypeError:
-		return formatErrorWithSource(e.Error(), e.Position(), source, filename, "Type Error")
+		return traceback + formatErrorWithSource(e.Error(), e.Position(), source, filename, "Type Error")
 	case ValueError:
-		return formatErrorWithSource(e.Error(), e.Position(), source, filename, "Value Error")
+		return traceback + formatErrorWithSource(e.Error(), e.Position(), source, filename, "Value Error")
 	case NameError:
-		return formatErrorWithSource(e.Error(), e.Position(), source, filename, "Name Error")
+		return traceback + formatErrorWithSource(e.Error(), e.Position(), source, filename, "Name Error")
 	case RuntimeError:
-		return formatErrorWithSource(e.Error(), e.Position(), source, filename, "Runtime Error")
+		return traceback + formatErrorWithSource(e.Error(), e.Position(), source, filename, "Runtime Error")
+	case LimitError:
+		return traceback + formatErrorWithSource(e.Error(), e.Position(), source, filename, "Limit Error")
+	case UserError:
+		return traceback + formatErrorWithSource(e.Error(), e.Position(), source, filename, "User Error")
 	default:
 		return fmt.Sprintf("%s: %s", filename, err.Error())
 	}
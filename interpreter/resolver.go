@@ -0,0 +1,529 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+)
+
+// binding is the location the resolver assigned to a variable reference or
+// assignment target.
+type binding struct {
+	// depth is the number of environments to walk up from the current one,
+	// or globalDepth if the variable lives in the global scope
+	depth int
+	// index is the slot of the variable in that environment
+	index int
+}
+
+// globalDepth marks a binding that is looked up by name in the global scope.
+const globalDepth = -1
+
+// scope describes the local variables of a function. Parameters take the
+// first slots, followed by every other name assigned in the function body.
+type scope struct {
+	names []string
+	slots map[string]int
+	// generator is set for the scope of a function containing a yield
+	// statement, which returns a generator when called
+	generator bool
+	// globals and nonlocals hold the names declared by global and nonlocal
+	// statements in the function, which aren't local when assigned
+	globals, nonlocals map[string]bool
+}
+
+func newScope(parameters []string) *scope {
+	s := &scope{slots: make(map[string]int), globals: make(map[string]bool), nonlocals: make(map[string]bool)}
+	for i, name := range parameters {
+		// A repeated parameter name refers to the last parameter
+		s.names = append(s.names, name)
+		s.slots[name] = i
+	}
+	return s
+}
+
+// declare adds a local variable to the scope if it isn't there already.
+func (s *scope) declare(name string) {
+	if _, ok := s.slots[name]; !ok {
+		s.slots[name] = len(s.names)
+		s.names = append(s.names, name)
+	}
+}
+
+// declareTarget adds the variables assigned by an assignment target to the
+// scope.
+func (s *scope) declareTarget(target Expression) {
+	switch t := target.(type) {
+	case *Variable:
+		s.declare(t.Name)
+	case *ArrayTarget:
+		for _, element := range t.Elements {
+			s.declareTarget(element)
+		}
+		if t.Rest != nil {
+			s.declareTarget(t.Rest)
+		}
+	case *ObjectTarget:
+		for _, value := range t.Values {
+			s.declareTarget(value)
+		}
+	}
+}
+
+// resolver is a static pass run after parsing that binds every variable to a
+// slot in the environment of the function that declares it, so the
+// interpreter can use slice indexes instead of looking names up in maps.
+// Names assigned anywhere in a function body are local to that function,
+// whether or not a variable with the same name exists in an enclosing
+// function or the global scope, unless a global or nonlocal statement in the
+// function declares them. Names assigned at the top level, and names not
+// declared by any enclosing function, are globals.
+type resolver struct {
+	// scopes holds the enclosing function scopes, innermost last
+	scopes []*scope
+	// current is the scope of the innermost enclosing function, which
+	// differs from the last of scopes in match arms
+	current *scope
+	// defined holds the names assigned at the top level
+	defined map[string]bool
+	// globalReads holds the variables read from the global scope
+	globalReads []*Variable
+	// imports holds the files imported by import statements
+	imports []string
+}
+
+func newResolver() *resolver {
+	return &resolver{defined: make(map[string]bool)}
+}
+
+// resolveProgram binds the variables of a program, unless that has been
+// done already.
+func resolveProgram(prog *Program) {
+	if prog.resolved {
+		return
+	}
+	newResolver().program(prog)
+	prog.resolved = true
+}
+
+// resolveExpression binds the variables of an expression evaluated in the
+// global scope.
+func resolveExpression(expr Expression) {
+	newResolver().expression(expr)
+}
+
+func (r *resolver) program(prog *Program) {
+	r.declare(prog.Statements)
+	r.block(prog.Statements)
+}
+
+// declare records the names assigned by the statements of a function body or
+// the top level, without descending into nested functions.
+func (r *resolver) declare(block Block) {
+	for _, s := range block {
+		switch s := s.(type) {
+		case *Assign:
+			r.declareTarget(s.Target)
+		case *For:
+			if s.Key != "" {
+				r.declareName(s.Key)
+			}
+			if s.Target != nil {
+				r.declareTarget(s.Target)
+			} else {
+				r.declareName(s.Name)
+			}
+		case *TryCatch:
+			if s.ErrVar != "" {
+				r.declareName(s.ErrVar)
+			}
+		case *FunctionDefinition:
+			r.declareName(s.Name)
+		}
+		for _, inner := range innerBlocks(s) {
+			r.declare(inner)
+		}
+	}
+}
+
+// declareOuter records the names declared by the global and nonlocal
+// statements of a function body, without descending into nested functions.
+// It runs before the names assigned in the body are declared, so the
+// statements apply to the whole body.
+func (r *resolver) declareOuter(block Block) {
+	s := r.scopes[len(r.scopes)-1]
+	for _, statement := range block {
+		if d, ok := statement.(*NameDeclaration); ok {
+			for _, name := range d.Names {
+				if _, ok := s.slots[name]; ok {
+					panic(Error{d.Position(), fmt.Sprintf("parameter %q can't be declared %s", name, d.keyword())})
+				}
+				if d.Global {
+					s.globals[name] = true
+					r.defined[name] = true
+					continue
+				}
+				if r.lookup(name).depth == globalDepth {
+					panic(Error{d.Position(), fmt.Sprintf("no variable %q in an enclosing function", name)})
+				}
+				s.nonlocals[name] = true
+			}
+		}
+		for _, inner := range innerBlocks(statement) {
+			r.declareOuter(inner)
+		}
+	}
+}
+
+// innerBlocks returns the blocks nested in a statement that run in the same
+// function, such as the body of a loop.
+func innerBlocks(s Statement) []Block {
+	switch s := s.(type) {
+	case *If:
+		return []Block{s.Body, s.Else}
+	case *While:
+		return []Block{s.Body}
+	case *For:
+		return []Block{s.Body}
+	case *Match:
+		blocks := make([]Block, len(s.Cases))
+		for i, arm := range s.Cases {
+			blocks[i] = arm.Body
+		}
+		return blocks
+	case *TryCatch:
+		return []Block{s.TryBlock, s.CatchBlock, s.FinallyBlock}
+	}
+	return nil
+}
+
+// declareTarget declares the variables assigned by an assignment target.
+func (r *resolver) declareTarget(target Expression) {
+	switch t := target.(type) {
+	case *Variable:
+		r.declareName(t.Name)
+	case *ArrayTarget:
+		for _, element := range t.Elements {
+			r.declareTarget(element)
+		}
+		if t.Rest != nil {
+			r.declareTarget(t.Rest)
+		}
+	case *ObjectTarget:
+		for _, value := range t.Values {
+			r.declareTarget(value)
+		}
+	}
+}
+
+func (r *resolver) declareName(name string) {
+	if len(r.scopes) == 0 {
+		r.defined[name] = true
+		return
+	}
+	s := r.scopes[len(r.scopes)-1]
+	if s.globals[name] || s.nonlocals[name] {
+		return
+	}
+	s.declare(name)
+}
+
+// lookup returns the binding of name as seen from the innermost scope. A
+// global statement makes the name global from its function inwards.
+func (r *resolver) lookup(name string) binding {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if r.scopes[i].globals[name] {
+			break
+		}
+		if index, ok := r.scopes[i].slots[name]; ok {
+			return binding{len(r.scopes) - 1 - i, index}
+		}
+	}
+	return binding{globalDepth, 0}
+}
+
+// function resolves the prologue and body of a function in a new scope and
+// returns the scope.
+func (r *resolver) function(parameters []string, prologue, body Block) *scope {
+	s := newScope(parameters)
+	outer := r.current
+	r.scopes, r.current = append(r.scopes, s), s
+	r.declareOuter(body)
+	r.declare(prologue)
+	r.declare(body)
+	r.block(prologue)
+	r.block(body)
+	r.scopes, r.current = r.scopes[:len(r.scopes)-1], outer
+	return s
+}
+
+func (r *resolver) block(block Block) {
+	for _, s := range block {
+		r.statement(s)
+	}
+}
+
+func (r *resolver) statement(s Statement) {
+	switch s := s.(type) {
+	case *Assign:
+		r.expression(s.Value)
+		r.target(s.Target)
+	case *If:
+		r.expression(s.Condition)
+		r.block(s.Body)
+		r.block(s.Else)
+	case *While:
+		r.expression(s.Condition)
+		r.block(s.Body)
+	case *For:
+		r.expression(s.Iterable)
+		if s.Key != "" {
+			s.keyBinding = r.lookup(s.Key)
+		}
+		if s.Target != nil {
+			r.target(s.Target)
+		} else {
+			s.binding = r.lookup(s.Name)
+		}
+		r.block(s.Body)
+	case *Match:
+		r.expression(s.Subject)
+		for i := range s.Cases {
+			r.matchCase(&s.Cases[i])
+		}
+	case *TryCatch:
+		r.block(s.TryBlock)
+		if s.ErrVar != "" {
+			s.errBinding = r.lookup(s.ErrVar)
+		}
+		r.block(s.CatchBlock)
+		r.block(s.FinallyBlock)
+	case *ExpressionStatement:
+		r.expression(s.Expression)
+	case *FunctionDefinition:
+		s.binding = r.lookup(s.Name)
+		s.scope = r.function(s.Parameters, s.prologue, s.Body)
+	case *Return:
+		r.expression(s.Result)
+	case *Throw:
+		r.expression(s.Value)
+	case *DefaultValue:
+		r.expression(s.Value)
+		r.target(s.Parameter)
+	case *Element:
+		r.expression(s.Key)
+		r.expression(s.Value)
+	case *Yield:
+		r.expression(s.Value)
+		if r.current != nil {
+			r.current.generator = true
+		}
+	case *Import:
+		r.imports = append(r.imports, s.Filename)
+	}
+}
+
+// target resolves the variables and subscripts of an assignment target.
+func (r *resolver) target(target Expression) {
+	switch t := target.(type) {
+	case *Variable:
+		t.binding = r.lookup(t.Name)
+	case *ArrayTarget:
+		for _, element := range t.Elements {
+			r.target(element)
+		}
+		if t.Rest != nil {
+			r.target(t.Rest)
+		}
+	case *ObjectTarget:
+		for _, value := range t.Values {
+			r.target(value)
+		}
+	default:
+		r.expression(target)
+	}
+}
+
+// matchCase resolves a case arm of a match statement. The variables
+// captured by its pattern get a scope of their own, so they are only visible
+// in the guard and body of the arm.
+func (r *resolver) matchCase(arm *MatchCase) {
+	captures := patternCaptures(arm.Pattern, nil)
+	if len(captures) == 0 {
+		r.expression(arm.Guard)
+		r.block(arm.Body)
+		return
+	}
+	s := newScope(nil)
+	for _, capture := range captures {
+		s.declare(capture.Name)
+		capture.slot = s.slots[capture.Name]
+	}
+	r.scopes = append(r.scopes, s)
+	r.expression(arm.Guard)
+	r.block(arm.Body)
+	r.scopes = r.scopes[:len(r.scopes)-1]
+	arm.scope = s
+}
+
+// comprehension resolves a comprehension in a scope of its own. Its first
+// slot holds the array or object being built, followed by the variables of
+// its for clauses, which are always local to the comprehension.
+func (r *resolver) comprehension(e *Comprehension) {
+	s := newScope([]string{""})
+	for clause := Statement(e.Loop); clause != e.Element; {
+		switch c := clause.(type) {
+		case *For:
+			if c.Key != "" {
+				s.declare(c.Key)
+			}
+			if c.Target != nil {
+				s.declareTarget(c.Target)
+			} else {
+				s.declare(c.Name)
+			}
+			clause = c.Body[0]
+		case *If:
+			clause = c.Body[0]
+		}
+	}
+	r.scopes = append(r.scopes, s)
+	r.statement(e.Loop)
+	r.scopes = r.scopes[:len(r.scopes)-1]
+	e.scope = s
+}
+
+// patternCaptures appends the variables bound by a pattern to captures.
+func patternCaptures(pattern Pattern, captures []*CapturePattern) []*CapturePattern {
+	switch p := pattern.(type) {
+	case *CapturePattern:
+		if p.Name != "_" {
+			captures = append(captures, p)
+		}
+	case *TypePattern:
+		if p.Capture != nil {
+			captures = patternCaptures(p.Capture, captures)
+		}
+	case *ArrayPattern:
+		for _, element := range p.Elements {
+			captures = patternCaptures(element, captures)
+		}
+		if p.Rest != nil {
+			captures = patternCaptures(p.Rest, captures)
+		}
+	case *ObjectPattern:
+		for _, value := range p.Values {
+			captures = patternCaptures(value, captures)
+		}
+	}
+	return captures
+}
+
+func (r *resolver) expression(expr Expression) {
+	switch e := expr.(type) {
+	case *Binary:
+		r.expression(e.Left)
+		r.expression(e.Right)
+	case *Unary:
+		r.expression(e.Operand)
+	case *Ternary:
+		r.expression(e.Condition)
+		r.expression(e.TrueExpr)
+		r.expression(e.FalseExpr)
+	case *Call:
+		r.expression(e.Function)
+		for _, arg := range e.Arguments {
+			r.expression(arg)
+		}
+	case *Variable:
+		e.binding = r.lookup(e.Name)
+		if e.binding.depth == globalDepth {
+			r.globalReads = append(r.globalReads, e)
+		}
+	case *List:
+		for _, v := range e.Values {
+			r.expression(v)
+		}
+	case *Interpolation:
+		for _, v := range e.Values {
+			r.expression(v)
+		}
+	case *Map:
+		for _, item := range e.Items {
+			r.expression(item.Key)
+			r.expression(item.Value)
+		}
+	case *Subscript:
+		r.expression(e.Container)
+		r.expression(e.Subscript)
+	case *OptionalChain:
+		r.expression(e.Expression)
+	case *Spread:
+		r.expression(e.Value)
+	case *Slice:
+		r.expression(e.Start)
+		r.expression(e.End)
+		r.expression(e.Step)
+	case *Comprehension:
+		r.comprehension(e)
+	case *FunctionExpression:
+		e.scope = r.function(e.Parameters, e.prologue, e.Body)
+	}
+}
+
+// unresolvedNames returns a name error for every variable that is read but
+// never defined: not a local of an enclosing function, not assigned at the
+// top level of the program or of a file it imports, and not a builtin.
+// Programs importing a file that can't be read or parsed are not checked,
+// since the missing file could define any name.
+func unresolvedNames(prog *Program) []error {
+	r := newResolver()
+	r.program(prog)
+
+	defined := make(map[string]bool)
+	for name := range builtins {
+		defined[name] = true
+	}
+	for name := range constants {
+		defined[name] = true
+	}
+	if !importedNames(r, defined, make(map[string]bool)) {
+		return nil
+	}
+
+	var errs []error
+	for _, v := range r.globalReads {
+		if !defined[v.Name] {
+			errs = append(errs, nameError(v.Position(), "name %q not found", v.Name))
+		}
+	}
+	return errs
+}
+
+// importedNames adds the names defined at the top level of r's program and
+// the files it imports to defined. It returns false if an import can't be
+// loaded.
+func importedNames(r *resolver, defined map[string]bool, visited map[string]bool) bool {
+	for name := range r.defined {
+		defined[name] = true
+	}
+	for _, filename := range r.imports {
+		if visited[filename] {
+			continue
+		}
+		visited[filename] = true
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return false
+		}
+		prog, err := ParseProgramFile(filename, content)
+		if err != nil {
+			return false
+		}
+		imported := newResolver()
+		imported.program(prog)
+		if !importedNames(imported, defined, visited) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,265 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolverBindings(t *testing.T) {
+	prog, err := ParseProgram([]byte(`
+	total = 0
+	fun outer(a, b):
+		c = a + b
+		inner = fun(d):
+			return c + d + total
+		end
+		return inner
+	end
+	`))
+	if err != nil {
+		t.Fatalf("Failed to parse program: %v", err)
+	}
+
+	outer := prog.Statements[1].(*FunctionDefinition)
+	if outer.binding.depth != globalDepth {
+		t.Errorf("Expected outer to be a global, got %+v", outer.binding)
+	}
+	if got := strings.Join(outer.scope.names, ","); got != "a,b,c,inner" {
+		t.Errorf("Expected outer slots a,b,c,inner, got %s", got)
+	}
+
+	inner := outer.Body[1].(*Assign).Value.(*FunctionExpression)
+	sum := inner.Body[0].(*Return).Result.(*Binary)
+	left := sum.Left.(*Binary)
+
+	tests := []struct {
+		name     string
+		variable *Variable
+		expected binding
+	}{
+		{"enclosing local", left.Left.(*Variable), binding{1, 2}},
+		{"parameter", left.Right.(*Variable), binding{0, 0}},
+		{"global", sum.Right.(*Variable), binding{globalDepth, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.variable.binding != test.expected {
+				t.Errorf("Expected %s to be bound to %+v, got %+v", test.variable.Name, test.expected, test.variable.binding)
+			}
+		})
+	}
+}
+
+func TestLexicalScoping(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "unassigned_local_falls_back_to_global",
+			program: `
+			x = 5
+			fun f():
+				print(x)
+				x = 10
+				print(x)
+			end
+			f()
+			print(x)
+			`,
+			expected: "5\n10\n5\n",
+		},
+		{
+			name: "enclosing_locals_outlive_their_function",
+			program: `
+			fun outer():
+				a = 1
+				fun middle():
+					b = 2
+					return fun():
+						return a + b
+					end
+				end
+				return middle()
+			end
+			print(outer()())
+			`,
+			expected: "3\n",
+		},
+		{
+			name: "callee_does_not_see_caller_locals",
+			program: `
+			fun g():
+				return y
+			end
+			fun h():
+				y = 1
+				return g()
+			end
+			try:
+				h()
+			catch (e):
+				print(e)
+			end
+			`,
+			expected: "name error at 3:12: name \"y\" not found\n",
+		},
+		{
+			name: "assignment_in_try_is_visible_after_it",
+			program: `
+			fun f():
+				try:
+					z = 3
+				catch (e):
+					z = 4
+				end
+				return z
+			end
+			print(f())
+			`,
+			expected: "3\n",
+		},
+		{
+			name: "inner_assignment_does_not_clobber_outer_variables",
+			program: `
+			x = "global"
+			fun outer():
+				y = "outer"
+				fun inner():
+					x = "inner x"
+					y = "inner y"
+					return [x, y]
+				end
+				return [inner(), y]
+			end
+			print(outer(), x)
+			`,
+			expected: "[[\"inner x\", \"inner y\"], \"outer\"] global\n",
+		},
+		{
+			name: "nonlocal_and_global",
+			program: `
+			total = 0
+			fun make_counter():
+				count = 0
+				fun increment(by):
+					nonlocal count
+					global total
+					count += by
+					total += by
+					return count
+				end
+				return increment
+			end
+			a = make_counter()
+			b = make_counter()
+			print(a(1), a(2), b(10), total)
+			`,
+			expected: "1 3 10 13\n",
+		},
+		{
+			name: "declarations_apply_to_the_whole_body",
+			program: `
+			fun f():
+				n = 1
+				fun g():
+					for (i in range(3)):
+						n += i
+					end
+					if (true) then:
+						nonlocal n
+					end
+					fun h():
+						return n
+					end
+					return h()
+				end
+				return [g(), n]
+			end
+			fun set_global():
+				global created
+				created = "yes"
+			end
+			set_global()
+			print(f(), created)
+			`,
+			expected: "[4, 4] yes\n",
+		},
+		{
+			name: "global_and_nonlocal_are_only_keywords_before_a_name",
+			program: `
+			global = 1
+			nonlocal = [global, 2]
+			fun f(nonlocal):
+				global
+				nonlocal = nonlocal + global
+				return nonlocal
+			end
+			print(nonlocal, f(global))
+			`,
+			expected: "[1, 2] 2\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestNameDeclarationErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{"nonlocal x", "parse error at 1:1: nonlocal outside of function"},
+		{"fun f():\nglobal x,\nend", "parse error at 3:1: expected name, but got end"},
+		{"fun f():\nnonlocal x\nend", "parse error at 2:1: no variable \"x\" in an enclosing function"},
+		{"x = 1\nfun f():\nfun g():\nnonlocal x\nend\nend", "parse error at 4:1: no variable \"x\" in an enclosing function"},
+		{"fun f(x):\nglobal x\nend", "parse error at 2:1: parameter \"x\" can't be declared global"},
+		{"fun f():\nx = 1\nfun g():\nglobal x\nfun h():\nnonlocal x\nend\nend\nend", "parse error at 6:1: no variable \"x\" in an enclosing function"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseProgram([]byte(test.input))
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected error containing %q for %q, got %v", test.message, test.input, err)
+		}
+	}
+}
+
+func TestAnalyzeSyntaxUnresolvedNames(t *testing.T) {
+	success, output := AnalyzeSyntax(`
+fun main():
+    print(helper(PI))
+end
+
+fun helper(x):
+    return x * totl
+end
+`)
+	if success {
+		t.Fatalf("Expected analysis to fail, got: %s", output)
+	}
+	if !strings.Contains(output, `name error at 7:16: name "totl" not found`) {
+		t.Errorf("Expected unresolved name to be reported, got: %s", output)
+	}
+	if strings.Contains(output, "helper") || strings.Contains(output, "PI") {
+		t.Errorf("Expected only totl to be reported, got: %s", output)
+	}
+
+	success, output = AnalyzeSyntax(`
+fun main():
+    print(square(3))
+end
+
+fun square(n):
+    return n * n
+end
+`)
+	if !success {
+		t.Errorf("Expected analysis to pass, got: %s", output)
+	}
+}
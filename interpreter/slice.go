@@ -0,0 +1,110 @@
+package interpreter
+
+// sliceBounds is the value of a slice subscript like start:end:step, which
+// only exists while the subscript is being read or assigned. Omitted bounds
+// are nil.
+type sliceBounds struct {
+	start, end, step Value
+}
+
+// indices resolves the bounds of a slice of a sequence of length n to the
+// index of its first element, its number of elements and its step. Like in
+// Python, negative bounds count from the end of the sequence, and bounds
+// past either end are clamped to it.
+func (b sliceBounds) indices(pos Position, n int) (start, count, step int) {
+	step = 1
+	if b.step != nil {
+		s, ok := b.step.(int)
+		if !ok {
+			panic(typeError(pos, "slice step must be an integer, got %s", typeName(b.step)))
+		}
+		if s == 0 {
+			panic(valueError(pos, "slice step can't be zero"))
+		}
+		step = s
+	}
+	// A slice stepping backwards starts at the last element and ends before
+	// the first one
+	first, last := 0, n
+	if step < 0 {
+		first, last = -1, n-1
+	}
+	bound := func(v Value, name string, omitted int) int {
+		if v == nil {
+			return omitted
+		}
+		i, ok := v.(int)
+		if !ok {
+			panic(typeError(pos, "slice %s must be an integer, got %s", name, typeName(v)))
+		}
+		if i < 0 {
+			i += n
+		}
+		return max(first, min(i, last))
+	}
+	if step > 0 {
+		start = bound(b.start, "start", first)
+		end := bound(b.end, "end", last)
+		count = max(0, (end-start+step-1)/step)
+	} else {
+		start = bound(b.start, "start", last)
+		end := bound(b.end, "end", first)
+		count = max(0, (start-end-step-1)/-step)
+	}
+	return start, count, step
+}
+
+// evalSlice returns the elements of a string or array selected by a slice,
+// as a new string or array. Strings are sliced by bytes, like they are
+// indexed.
+func evalSlice(pos Position, container Value, bounds sliceBounds) Value {
+	switch c := container.(type) {
+	case string:
+		start, count, step := bounds.indices(pos, len(c))
+		if step == 1 {
+			return Value(c[start : start+count])
+		}
+		result := make([]byte, count)
+		for i := range result {
+			result[i] = c[start+i*step]
+		}
+		return Value(string(result))
+	case *[]Value:
+		start, count, step := bounds.indices(pos, len(*c))
+		result := make([]Value, count)
+		for i := range result {
+			result[i] = (*c)[start+i*step]
+		}
+		return Value(&result)
+	default:
+		panic(typeError(pos, "can only slice string or array, got %s", typeName(container)))
+	}
+}
+
+// assignSlice replaces the elements of an array selected by a slice with the
+// values of an iterable. A slice with a step of 1 can be replaced by any
+// number of values, growing or shrinking the array; other slices need one
+// value per element.
+func (interp *interpreter) assignSlice(pos Position, container Value, bounds sliceBounds, value Value) {
+	array, ok := container.(*[]Value)
+	if !ok {
+		panic(typeError(pos, "can only assign to a slice of an array, got %s", typeName(container)))
+	}
+	start, count, step := bounds.indices(pos, len(*array))
+	values := interp.spreadValues(pos, nil, value)
+	if step == 1 {
+		result := make([]Value, 0, len(*array)-count+len(values))
+		result = append(result, (*array)[:start]...)
+		result = append(result, values...)
+		result = append(result, (*array)[start+count:]...)
+		*array = result
+		return
+	}
+	if len(values) != count {
+		panic(valueError(pos, "can't assign %d value%s to a slice of %d element%s",
+			len(values), plural(len(values)), count, plural(count)))
+	}
+	for i, v := range values {
+		(*array)[start+i*step] = v
+	}
+}
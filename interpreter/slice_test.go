@@ -0,0 +1,127 @@
+package interpreter
+
+import (
+	"testing"
+)
+
+func TestSlicing(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "arrays",
+			program: `
+			xs = [0, 1, 2, 3, 4, 5]
+			print(xs[1:4], xs[:-1], xs[::2], xs[-1], xs[:])
+			print(xs[::-1], xs[4:1:-1], xs[-2::-2])`,
+			expected: "[1, 2, 3] [0, 1, 2, 3, 4] [0, 2, 4] 5 [0, 1, 2, 3, 4, 5]\n" +
+				"[5, 4, 3, 2, 1, 0] [4, 3, 2] [4, 2, 0]\n",
+		},
+		{
+			name: "bounds_are_clamped",
+			program: `
+			xs = [0, 1, 2]
+			print(xs[10:], xs[-100:2], xs[2:1], xs[null:1], xs[5:-5:-1])`,
+			expected: "[] [0, 1] [] [0] [2, 1, 0]\n",
+		},
+		{
+			name: "strings",
+			program: `
+			s = "hello"
+			print(s[1:3], s[::-1], s[-3:], s[::2], s[-1])
+			u = "héllo"
+			print(len(u), u[1:3], u[3:], find(u, "l"), len([c for c in u]))`,
+			expected: "el olleh llo hlo o\n6 é llo 3 5\n",
+		},
+		{
+			name: "slices_are_copies",
+			program: `
+			xs = [1, 2, 3]
+			ys = xs[:]
+			ys[0] = 9
+			print(xs, ys)`,
+			expected: "[1, 2, 3] [9, 2, 3]\n",
+		},
+		{
+			name: "assignment",
+			program: `
+			xs = [1, 2, 3, 4]
+			ys = xs
+			xs[1:3] = [9]
+			print(ys)
+			xs[0:0] = "ab"
+			print(xs)
+			xs[::2] = [0, 0, 0]
+			print(xs)
+			xs[-1] = 7
+			xs[:2] += [5]
+			print(xs)
+			xs[-2:] = []
+			print(xs)`,
+			expected: "[1, 9, 4]\n[\"a\", \"b\", 1, 9, 4]\n[0, \"b\", 0, 9, 0]\n" +
+				"[0, \"b\", 5, 0, 9, 7]\n[0, \"b\", 5, 0]\n",
+		},
+		{
+			name: "ternary_index",
+			program: `
+			xs = [0, 1, 2, 3]
+			c = true
+			print(xs[c ? 1 : 2], xs[1:c ? 3 : 4])`,
+			expected: "1 [1, 2]\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestSliceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"zero_step", "x = [1][::0]", "value error at 1:9: slice step can't be zero"},
+		{"bound_type", "x = [1][\"a\":]", "type error at 1:9: slice start must be an integer, got string"},
+		{"step_type", "x = [1][::1.5]", "type error at 1:9: slice step must be an integer, got float"},
+		{"container", "x = {}[1:]", "type error at 1:8: can only slice string or array, got object"},
+		{"extended_length", "x = [1, 2]\nx[::2] = [1, 2]", "value error at 2:3: can't assign 2 values to a slice of 1 element"},
+		{"string_assignment", "x = \"ab\"\nx[0:1] = \"c\"", "type error at 2:3: can only assign to a slice of an array, got string"},
+		{"not_iterable", "x = [1]\nx[:] = 5", "type error at 2:3: expected iterable (string, array, object or iterator), got integer"},
+		{"negative_index", "x = [1]\nx[-2] = 5", "value error at 2:3: subscript -1 out of range"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, test.program, test.message)
+		})
+	}
+}
+
+func TestParseSlice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs[1:4]", "xs[1:4]"},
+		{"xs[:-1]", "xs[:(-1)]"},
+		{"xs[::2]", "xs[::2]"},
+		{"xs[a:b:]", "xs[a:b]"},
+		{"xs[:]", "xs[:]"},
+	}
+
+	for _, test := range tests {
+		expr, err := ParseExpression([]byte(test.input))
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", test.input, err)
+		}
+		if expr.String() != test.expected {
+			t.Errorf("Expected %q for %q, got %q", test.expected, test.input, expr.String())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package interpreter
+
+import (
+	"testing"
+)
+
+func TestSpreadInLiterals(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "arrays",
+			program: `
+			a = [1, 2]
+			b = [5]
+			print([...a, 0, ...b], [...a], [...[]], [0, ...[...b, 6]])`,
+			expected: "[1, 2, 0, 5] [1, 2] [] [0, 5, 6]\n",
+		},
+		{
+			name: "iterables",
+			program: `
+			fun pair():
+				yield "x"
+				yield "y"
+			end
+			print([..."ab", ...{k: 1}, ...pair()])`,
+			expected: "[\"a\", \"b\", \"k\", \"x\", \"y\"]\n",
+		},
+		{
+			name: "copies_arrays",
+			program: `
+			a = [1]
+			b = [...a]
+			b[0] = 2
+			print(a, b)`,
+			expected: "[1] [2]\n",
+		},
+		{
+			name: "objects",
+			program: `
+			defaults = {host: "localhost", port: 80, debug: false}
+			overrides = {port: 8080}
+			print({...defaults, ...overrides, debug: true})
+			print({debug: true, ...defaults})
+			print({...{}}, {"a" + "b": 1, ...{c: 2}})`,
+			expected: "{\"host\": \"localhost\", \"port\": 8080, \"debug\": true}\n" +
+				"{\"debug\": false, \"host\": \"localhost\", \"port\": 80}\n" +
+				"{} {\"ab\": 1, \"c\": 2}\n",
+		},
+		{
+			name: "error_objects",
+			program: `
+			try:
+				throw {message: "oops", code: 7}
+			catch (e):
+				details = {...e, handled: true}
+				print(details.code, details.handled)
+			end`,
+			expected: "7 true\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestSpreadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"array", "x = [1, ...5]", "type error at 1:9: expected iterable (string, array, object or iterator), got integer"},
+		{"object", "x = {a: 1, ...[1]}", "type error at 1:12: expected object to spread, got array"},
+		{"key_after_spread", "x = {...{}, 1: 2}", "type error at 1:13: object key must be string, not integer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, test.program, test.message)
+		})
+	}
+}
+
+func TestParseSpread(t *testing.T) {
+	expr, err := ParseExpression([]byte("[...a, 0, ...f(b)] + {...c, d: 1}"))
+	if err != nil {
+		t.Fatalf("Failed to parse expression: %v", err)
+	}
+	expected := "([...a, 0, ...f(b)] + {...c, \"d\": 1})"
+	if expr.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, expr.String())
+	}
+}
@@ -2,7 +2,9 @@ package interpreter
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -35,6 +37,10 @@ const (
 	RPAREN
 	TIMES
 	QUESTION
+	BITAND
+	BITOR
+	BITXOR
+	BITNOT
 
 	// Alternative block tokens
 	END
@@ -49,61 +55,91 @@ const (
 	TIMESEQUAL
 	DIVIDEEQUAL
 	MODULOEQUAL
+	SHL
+	SHR
+	POWER
+	BITANDEQUAL
+	BITOREQUAL
+	BITXOREQUAL
+	OPTIONAL
+	NULLISH
 
 	// Three-character tokens
 	ELLIPSIS
+	SHLEQUAL
+	SHREQUAL
+	POWEREQUAL
+	NULLISHEQUAL
 
 	// Keywords
 	AND
 	BREAK
+	CASE
 	CATCH
 	CONTINUE
+	DEFAULT
 	ELSE
 	FALSE
+	FINALLY
 	FOR
 	FUN
 	IF
 	IMPORT
 	IN
+	MATCH
 	NULL
 	NOT
 	OR
 	RETURN
 	THEN
+	THROW
 	TRUE
 	TRY
 	WHILE
 	XOR
+	YIELD
 
 	// Literals and identifiers
 	INT
 	FLOAT
 	NAME
 	STR
+
+	// Parts of a string literal with ${...} interpolations: the text before
+	// the first one, between two of them, and after the last one
+	STRSTART
+	STRMID
+	STREND
 )
 
 var keywordTokens = map[string]Token{
 	"and":      AND,
 	"break":    BREAK,
+	"case":     CASE,
 	"catch":    CATCH,
 	"continue": CONTINUE,
+	"default":  DEFAULT,
 	"else":     ELSE,
 	"end":      END,
 	"false":    FALSE,
+	"finally":  FINALLY,
 	"for":      FOR,
 	"fun":      FUN,
 	"if":       IF,
 	"import":   IMPORT,
 	"in":       IN,
+	"match":    MATCH,
 	"null":     NULL,
 	"not":      NOT,
 	"or":       OR,
 	"return":   RETURN,
 	"then":     THEN,
+	"throw":    THROW,
 	"true":     TRUE,
 	"try":      TRY,
 	"while":    WHILE,
 	"xor":      XOR,
+	"yield":    YIELD,
 }
 
 var tokenNames = map[Token]string{
@@ -128,6 +164,10 @@ var tokenNames = map[Token]string{
 	RPAREN:   ")",
 	TIMES:    "*",
 	QUESTION: "?",
+	BITAND:   "&",
+	BITOR:    "|",
+	BITXOR:   "^",
+	BITNOT:   "~",
 
 	END: "end",
 
@@ -140,45 +180,77 @@ var tokenNames = map[Token]string{
 	TIMESEQUAL:  "*=",
 	DIVIDEEQUAL: "/=",
 	MODULOEQUAL: "%=",
-
-	ELLIPSIS: "...",
+	SHL:         "<<",
+	SHR:         ">>",
+	POWER:       "**",
+	BITANDEQUAL: "&=",
+	BITOREQUAL:  "|=",
+	BITXOREQUAL: "^=",
+	OPTIONAL:    "?.",
+	NULLISH:     "??",
+
+	ELLIPSIS:     "...",
+	SHLEQUAL:     "<<=",
+	SHREQUAL:     ">>=",
+	POWEREQUAL:   "**=",
+	NULLISHEQUAL: "??=",
 
 	AND:      "and",
 	BREAK:    "break",
+	CASE:     "case",
 	CATCH:    "catch",
 	CONTINUE: "continue",
+	DEFAULT:  "default",
 	ELSE:     "else",
 	FALSE:    "false",
+	FINALLY:  "finally",
 	FOR:      "for",
 	FUN:      "fun",
 	IF:       "if",
 	IMPORT:   "import",
 	IN:       "in",
+	MATCH:    "match",
 	NULL:     "null",
 	NOT:      "not",
 	OR:       "or",
 	RETURN:   "return",
 	THEN:     "then",
+	THROW:    "throw",
 	TRUE:     "true",
 	TRY:      "try",
 	WHILE:    "while",
 	XOR:      "xor",
+	YIELD:    "yield",
 
 	INT:   "int",
 	FLOAT: "float",
 	NAME:  "name",
 	STR:   "str",
+
+	STRSTART: "str",
+	STRMID:   "}",
+	STREND:   "}",
 }
 
 func (t Token) String() string {
 	return tokenNames[t]
 }
 
-// Position stores the line and column where a token starts in the source code
+// Position stores the file, line and column where a token starts in the source code
 // This is used for error reporting and debugging purposes
 type Position struct {
-	Line   int // 1-based line number
-	Column int // 1-based column number
+	Line   int    // 1-based line number
+	Column int    // 1-based column number
+	File   string // Name of the source file, empty for the main program
+}
+
+// String returns the position as "line:column", prefixed with "file:" for
+// positions in a named source file.
+func (p Position) String() string {
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 // Tokenizer parses input source code to a stream of tokens.
@@ -191,6 +263,23 @@ type Tokenizer struct {
 	errorMsg string   // Error message if an error occurred
 	pos      Position // Current position in the source
 	nextPos  Position // Next position in the source
+
+	// braces counts the braces opened and not yet closed in the innermost
+	// ${...} interpolation of a string
+	braces int
+	// interpolations holds the strings whose interpolations are being
+	// tokenized, innermost last
+	interpolations []interpolation
+	// indents holds the indentation of the lines of a triple-quoted string
+	// while it is being measured
+	indents []string
+}
+
+// interpolation records a string literal whose ${...} interpolation is
+// being tokenized.
+type interpolation struct {
+	kind   stringKind // The quoting of the string
+	braces int        // The brace count of the enclosing interpolation
 }
 
 // NewTokenizer creates and initializes a new tokenizer for the given input.
@@ -200,8 +289,20 @@ type Tokenizer struct {
 //
 // Returns a pointer to the initialized Tokenizer
 func NewTokenizer(input []byte) *Tokenizer {
+	return NewFileTokenizer("", input)
+}
+
+// NewFileTokenizer creates a tokenizer for the source code of a named file.
+// The positions of its tokens record the filename.
+// Parameters:
+//   - filename: The name of the source file
+//   - input: The source code as a byte array
+//
+// Returns a pointer to the initialized Tokenizer
+func NewFileTokenizer(filename string, input []byte) *Tokenizer {
 	t := new(Tokenizer)
 	t.input = input
+	t.nextPos.File = filename
 	t.nextPos.Line = 1   // Start at line 1
 	t.nextPos.Column = 1 // Start at column 1
 	t.next()             // Read the first character
@@ -368,6 +469,13 @@ func (t *Tokenizer) Next() (Position, Token, string) {
 
 	// Process names (identifiers) and keywords
 	if isNameStart(ch) {
+		// An r right before a quote starts a raw string
+		if ch == 'r' && (t.ch == '"' || t.ch == '\'') {
+			quote := t.ch
+			t.next()
+			return t.openString(pos, stringKind{quote: quote, raw: true})
+		}
+
 		// Collect all characters that can be part of a name
 		runes := []rune{ch}
 		for isNameStart(t.ch) || (t.ch >= '0' && t.ch <= '9') {
@@ -398,6 +506,9 @@ func (t *Tokenizer) Next() (Position, Token, string) {
 			token = DIVIDE
 		}
 	case '{':
+		if len(t.interpolations) > 0 {
+			t.braces++
+		}
 		token = LBRACE
 	case '[':
 		token = LBRACKET
@@ -425,6 +536,16 @@ func (t *Tokenizer) Next() (Position, Token, string) {
 			token = PLUS
 		}
 	case '}':
+		if len(t.interpolations) > 0 {
+			if t.braces == 0 {
+				// The end of an interpolation: the string continues
+				last := t.interpolations[len(t.interpolations)-1]
+				t.interpolations = t.interpolations[:len(t.interpolations)-1]
+				t.braces = last.braces
+				return t.stringLiteral(pos, last.kind, true)
+			}
+			t.braces--
+		}
 		token = RBRACE
 	case ']':
 		token = RBRACKET
@@ -434,11 +555,54 @@ func (t *Tokenizer) Next() (Position, Token, string) {
 		if t.ch == '=' {
 			t.next()
 			token = TIMESEQUAL
+		} else if t.ch == '*' {
+			t.next()
+			token = POWER
+			if t.ch == '=' {
+				t.next()
+				token = POWEREQUAL
+			}
 		} else {
 			token = TIMES
 		}
 	case '?':
-		token = QUESTION
+		if t.ch == '?' {
+			t.next()
+			token = NULLISH
+			if t.ch == '=' {
+				t.next()
+				token = NULLISHEQUAL
+			}
+		} else if t.ch == '.' && (t.offset == len(t.input) || t.input[t.offset] < '0' || t.input[t.offset] > '9') {
+			// In c ?.5 : 1, ? is the ternary operator
+			t.next()
+			token = OPTIONAL
+		} else {
+			token = QUESTION
+		}
+	case '&':
+		if t.ch == '=' {
+			t.next()
+			token = BITANDEQUAL
+		} else {
+			token = BITAND
+		}
+	case '|':
+		if t.ch == '=' {
+			t.next()
+			token = BITOREQUAL
+		} else {
+			token = BITOR
+		}
+	case '^':
+		if t.ch == '=' {
+			t.next()
+			token = BITXOREQUAL
+		} else {
+			token = BITXOR
+		}
+	case '~':
+		token = BITNOT
 
 	case '=':
 		if t.ch == '=' {
@@ -459,6 +623,13 @@ func (t *Tokenizer) Next() (Position, Token, string) {
 		if t.ch == '=' {
 			t.next()
 			token = LTE
+		} else if t.ch == '<' {
+			t.next()
+			token = SHL
+			if t.ch == '=' {
+				t.next()
+				token = SHLEQUAL
+			}
 		} else {
 			token = LT
 		}
@@ -466,11 +637,22 @@ func (t *Tokenizer) Next() (Position, Token, string) {
 		if t.ch == '=' {
 			t.next()
 			token = GTE
+		} else if t.ch == '>' {
+			t.next()
+			token = SHR
+			if t.ch == '=' {
+				t.next()
+				token = SHREQUAL
+			}
 		} else {
 			token = GT
 		}
 
 	case '.':
+		if t.ch >= '0' && t.ch <= '9' {
+			// A float with no digits before the point: .5
+			return t.number(pos, ch)
+		}
 		if t.ch == '.' {
 			t.next()
 			if t.ch != '.' {
@@ -483,120 +665,385 @@ func (t *Tokenizer) Next() (Position, Token, string) {
 		}
 	// Process numeric literals (integers and floats)
 	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		runes := []rune{ch}
-		isFloat := false
+		return t.number(pos, ch)
 
-		// Collect all digits and at most one decimal point
-		for t.ch >= '0' && t.ch <= '9' || t.ch == '.' {
-			if t.ch == '.' {
-				if isFloat {
-					return pos, ILLEGAL, "unexpected second '.' in number"
-				}
-				isFloat = true
+	// Process string literals enclosed in double or single quotes
+	case '"', '\'':
+		return t.openString(pos, stringKind{quote: ch})
+
+	default:
+		token = ILLEGAL
+		value = fmt.Sprintf("unexpected %c", ch)
+	}
+	return pos, token, value
+}
+
+// number tokenizes a numeric literal starting with ch, a digit or a decimal
+// point. Integers can be written in decimal, or in hex, octal or binary with
+// a 0x, 0o or 0b prefix. Floats are decimal, with a decimal point, an
+// exponent, or both. Underscores can separate digits, or follow a base
+// prefix: 1_000_000, 0x_FF.
+//
+// Returns INT or FLOAT with the literal as written, which the parser
+// converts to a number.
+func (t *Tokenizer) number(pos Position, ch rune) (Position, Token, string) {
+	runes := []rune{ch}
+	if ch == '0' && strings.ContainsRune("xXoObB", t.ch) {
+		// Integer with a base prefix
+		prefix := t.ch
+		base := numberBases[unicode.ToLower(prefix)]
+		runes = append(runes, prefix)
+		t.next()
+		// Like in Go, an underscore may follow the prefix: 0x_FF
+		if t.ch == '_' {
+			runes = append(runes, t.ch)
+			t.next()
+		}
+		start := len(runes)
+		for isNameStart(t.ch) || t.ch >= '0' && t.ch <= '9' {
+			if t.ch != '_' && !strings.ContainsRune(base.digits, t.ch) {
+				return t.pos, ILLEGAL, fmt.Sprintf("invalid digit %q in %s literal", t.ch, base.name)
 			}
 			runes = append(runes, t.ch)
 			t.next()
 		}
-
-		// Determine if it's an integer or float based on presence of decimal point
-		if isFloat {
-			token = FLOAT
-		} else {
-			token = INT
+		if len(runes) == start {
+			return pos, ILLEGAL, fmt.Sprintf("expected digits after 0%c", prefix)
 		}
-		value = string(runes)
-
-	// Process string literals enclosed in double quotes
-	case '"':
-		runes := []rune{}
-
-		// Collect all characters until closing quote
-		for t.ch != '"' {
-			c := t.ch
+		if msg := checkSeparators(runes[start:], base.digits); msg != "" {
+			return pos, ILLEGAL, msg
+		}
+		return pos, INT, string(runes)
+	}
 
-			// Check for unterminated string
-			if c < 0 {
-				return pos, ILLEGAL, "didn't find end quote in string"
+	// Decimal digits, with at most one decimal point
+	isFloat := ch == '.'
+	for t.ch >= '0' && t.ch <= '9' || t.ch == '_' || t.ch == '.' {
+		if t.ch == '.' {
+			if isFloat {
+				return pos, ILLEGAL, "unexpected second '.' in number"
 			}
+			isFloat = true
+		}
+		runes = append(runes, t.ch)
+		t.next()
+	}
 
-			// Strings cannot contain raw newlines
-			if c == '\r' || c == '\n' {
-				return pos, ILLEGAL, "can't have newline in string"
+	// An exponent, if the e is followed by digits: 6.02e23, 1E-9
+	if t.ch == 'e' || t.ch == 'E' {
+		rest := t.input[t.offset:]
+		if len(rest) > 0 && (rest[0] == '+' || rest[0] == '-') {
+			rest = rest[1:]
+		}
+		if len(rest) > 0 && rest[0] >= '0' && rest[0] <= '9' {
+			isFloat = true
+			runes = append(runes, t.ch)
+			t.next()
+			if t.ch == '+' || t.ch == '-' {
+				runes = append(runes, t.ch)
+				t.next()
 			}
-
-			// Handle escape sequences
-			if c == '\\' {
+			for t.ch >= '0' && t.ch <= '9' || t.ch == '_' {
+				runes = append(runes, t.ch)
 				t.next()
-				switch t.ch {
-				case '"', '\\':
-					c = t.ch // Quote or backslash
-				case 't':
-					c = '\t' // Tab
-				case 'r':
-					c = '\r' // Carriage return
-				case 'n':
-					c = '\n' // Newline
-				default:
-					return pos, ILLEGAL, fmt.Sprintf("invalid string escape \\%c", t.ch)
-				}
 			}
+		}
+	}
 
-			runes = append(runes, c)
-			t.next()
+	if msg := checkSeparators(runes, decimalDigits); msg != "" {
+		return pos, ILLEGAL, msg
+	}
+	if isFloat {
+		return pos, FLOAT, string(runes)
+	}
+	return pos, INT, string(runes)
+}
+
+const decimalDigits = "0123456789"
+
+// numberBases maps the lowercase letter of the prefix of a non-decimal
+// integer literal to the name of its base and its digits.
+var numberBases = map[rune]struct{ name, digits string }{
+	'x': {"hex", "0123456789abcdefABCDEF"},
+	'o': {"octal", "01234567"},
+	'b': {"binary", "01"},
+}
+
+// checkSeparators returns an error message if an underscore in a number
+// doesn't sit between two of the given digits.
+func checkSeparators(runes []rune, digits string) string {
+	isDigit := func(i int) bool {
+		return i >= 0 && i < len(runes) && strings.ContainsRune(digits, runes[i])
+	}
+	for i, c := range runes {
+		if c == '_' && (!isDigit(i-1) || !isDigit(i+1)) {
+			return "'_' must separate digits in number"
 		}
+	}
+	return ""
+}
+
+// stringKind describes how a string literal is quoted.
+type stringKind struct {
+	quote  rune // The quote that ends the string
+	triple bool // Triple-quoted: can span lines, and common indentation is stripped
+	raw    bool // Raw: backslashes and ${ are kept as written
 
-		// Skip the closing quote
+	// indent is the indentation stripped from the lines of a triple-quoted
+	// string. It is set while it is being measured.
+	indent    string
+	measuring bool
+}
+
+// openString tokenizes a string literal after its first quote, checking for
+// the two more quotes starting a triple-quoted string. A line break right
+// after the opening quotes of a triple-quoted string isn't part of it.
+func (t *Tokenizer) openString(pos Position, kind stringKind) (Position, Token, string) {
+	if t.ch != kind.quote || !t.followedBy(kind.quote, 1) {
+		return t.stringLiteral(pos, kind, false)
+	}
+	t.next() // Skip second quote
+	t.next() // Skip third quote
+	kind.triple = true
+	firstLine := false
+	if t.ch == '\r' && t.followedBy('\n', 1) {
 		t.next()
-		token = STR
-		value = string(runes)
+	}
+	if t.ch == '\n' {
+		t.next()
+		firstLine = true
+	}
+	kind.indent = t.commonIndent(kind, firstLine)
+	if firstLine {
+		t.lineStart(kind)
+	}
+	return t.stringLiteral(pos, kind, false)
+}
 
-	// Process string literals enclosed in single quotes
-	case '\'':
-		runes := []rune{}
+// commonIndent returns the longest run of spaces and tabs that starts every
+// line of a triple-quoted string, not counting blank lines and the first line
+// unless it starts after a line break. It works on a copy of the tokenizer,
+// which measures the lines of the string while tokenizing it to its end.
+func (t *Tokenizer) commonIndent(kind stringKind, firstLine bool) string {
+	scan := *t
+	scan.braces, scan.interpolations, scan.indents = 0, nil, nil
+	kind.measuring = true
+	token := STRSTART
+	if !firstLine || !scan.lineStart(kind) {
+		_, token, _ = scan.stringLiteral(Position{}, kind, false)
+	}
+	// The interpolations of the string are tokenized until it continues for
+	// the last time
+	for len(scan.interpolations) > 0 && token != ILLEGAL && token != EOF {
+		_, token, _ = scan.Next()
+	}
 
-		// Collect all characters until closing quote
-		for t.ch != '\'' {
-			c := t.ch
+	if len(scan.indents) == 0 {
+		return ""
+	}
+	indent := scan.indents[0]
+	for _, s := range scan.indents[1:] {
+		n := 0
+		for n < len(indent) && n < len(s) && indent[n] == s[n] {
+			n++
+		}
+		indent = indent[:n]
+	}
+	return indent
+}
 
-			// Check for unterminated string
-			if c < 0 {
-				return pos, ILLEGAL, "didn't find end quote in string"
-			}
+// lineStart is called at the start of every line of a triple-quoted string
+// but the first. It skips the common indentation of the line, or records the
+// indentation while it is being measured. If only spaces and tabs are left
+// before the closing quotes, they are skipped and lineStart returns true.
+func (t *Tokenizer) lineStart(kind stringKind) bool {
+	if t.ch < 0 {
+		return false
+	}
+	start := t.offset - utf8.RuneLen(t.ch)
+	end := start
+	for end < len(t.input) && (t.input[end] == ' ' || t.input[end] == '\t') {
+		end++
+	}
+	rest := t.input[end:]
+	if len(rest) >= 3 && rest[0] == byte(kind.quote) && rest[1] == byte(kind.quote) && rest[2] == byte(kind.quote) {
+		for t.ch == ' ' || t.ch == '\t' {
+			t.next()
+		}
+		return true
+	}
+
+	if kind.measuring {
+		// Blank lines don't count
+		if len(rest) > 0 && rest[0] != '\n' && rest[0] != '\r' {
+			t.indents = append(t.indents, string(t.input[start:end]))
+		}
+		return false
+	}
+	for i := 0; i < len(kind.indent) && t.ch == rune(kind.indent[i]); i++ {
+		t.next()
+	}
+	return false
+}
+
+// followedBy reports whether the n bytes after the current character are
+// all ch, which must be an ASCII character.
+func (t *Tokenizer) followedBy(ch rune, n int) bool {
+	if t.offset+n > len(t.input) {
+		return false
+	}
+	for _, b := range t.input[t.offset : t.offset+n] {
+		if rune(b) != ch {
+			return false
+		}
+	}
+	return true
+}
+
+// stringLiteral tokenizes the characters of a string literal up to its
+// closing quotes, which are skipped. It starts after the opening quotes, or
+// after the } ending an interpolation if continued is set. A ${ starting an
+// interpolation ends the token, and the tokens of the interpolated expression
+// follow it.
+//
+// Returns STR for a whole string literal, or the STRSTART, STRMID or STREND
+// part of a string with interpolations.
+func (t *Tokenizer) stringLiteral(pos Position, kind stringKind, continued bool) (Position, Token, string) {
+	runes := []rune{}
+
+	// Collect all characters until closing quotes
+	for t.ch != kind.quote || kind.triple && !t.followedBy(kind.quote, 2) {
+		c := t.ch
+
+		// Check for unterminated string
+		if c < 0 {
+			return pos, ILLEGAL, "didn't find end quote in string"
+		}
 
-			// Strings cannot contain raw newlines
-			if c == '\r' || c == '\n' {
+		// Only triple-quoted strings can span lines
+		if c == '\r' || c == '\n' {
+			if !kind.triple {
 				return pos, ILLEGAL, "can't have newline in string"
 			}
-
-			// Handle escape sequences
-			if c == '\\' {
-				t.next()
-				switch t.ch {
-				case '\'', '\\':
-					c = t.ch // Quote or backslash
-				case 't':
-					c = '\t' // Tab
-				case 'r':
-					c = '\r' // Carriage return
-				case 'n':
-					c = '\n' // Newline
-				default:
-					return pos, ILLEGAL, fmt.Sprintf("invalid string escape \\%c", t.ch)
-				}
+			t.next()
+			if c == '\r' && t.ch == '\n' {
+				continue // The \n of a \r\n line break is kept instead
+			}
+			if !t.lineStart(kind) {
+				runes = append(runes, c)
 			}
+			continue
+		}
 
+		// Raw strings have no interpolations or escape sequences
+		if kind.raw {
 			runes = append(runes, c)
 			t.next()
+			continue
+		}
+
+		// Start of an interpolation
+		if c == '$' && t.followedBy('{', 1) {
+			t.next() // Skip '$'
+			t.next() // Skip '{'
+			t.interpolations = append(t.interpolations, interpolation{kind, t.braces})
+			t.braces = 0
+			if continued {
+				return pos, STRMID, string(runes)
+			}
+			return pos, STRSTART, string(runes)
+		}
+
+		// Handle escape sequences, reporting errors at the backslash
+		if c == '\\' {
+			escapePos := t.pos
+			t.next()
+			if t.ch < 0 {
+				return pos, ILLEGAL, "didn't find end quote in string"
+			}
+			var msg string
+			if c, msg = t.escape(); msg != "" {
+				return escapePos, ILLEGAL, msg
+			}
+			runes = append(runes, c)
+			continue
 		}
 
-		// Skip the closing quote
+		runes = append(runes, c)
 		t.next()
-		token = STR
-		value = string(runes)
+	}
 
-	default:
-		token = ILLEGAL
-		value = fmt.Sprintf("unexpected %c", ch)
+	// Skip the closing quotes
+	t.next()
+	if kind.triple {
+		t.next()
+		t.next()
 	}
-	return pos, token, value
+	if continued {
+		return pos, STREND, string(runes)
+	}
+	return pos, STR, string(runes)
+}
+
+// escape decodes the escape sequence after a backslash in a string literal.
+// Returns the character it stands for, or an error message if it isn't
+// valid.
+func (t *Tokenizer) escape() (rune, string) {
+	c := t.ch
+	t.next()
+	switch c {
+	case '"', '\'', '\\', '$':
+		return c, "" // Quote, backslash or dollar sign
+	case 'n':
+		return '\n', "" // Newline
+	case 't':
+		return '\t', "" // Tab
+	case 'r':
+		return '\r', "" // Carriage return
+	case '0':
+		return 0, "" // Null character
+	case 'a':
+		return '\a', "" // Bell
+	case 'b':
+		return '\b', "" // Backspace
+	case 'f':
+		return '\f', "" // Form feed
+	case 'v':
+		return '\v', "" // Vertical tab
+	case 'x':
+		// Exactly two hex digits: \x41
+		digits := t.hexDigits(2)
+		if len(digits) != 2 {
+			return 0, fmt.Sprintf("invalid escape \\x%s: expected 2 hex digits", digits)
+		}
+		n, _ := strconv.ParseUint(digits, 16, 8)
+		return rune(n), ""
+	case 'u':
+		// One to six hex digits in braces: \u{1F600}
+		if t.ch != '{' {
+			return 0, "invalid escape \\u: expected { after \\u"
+		}
+		t.next()
+		digits := t.hexDigits(6)
+		if digits == "" || t.ch != '}' {
+			return 0, fmt.Sprintf("invalid escape \\u{%s: expected 1 to 6 hex digits and }", digits)
+		}
+		t.next()
+		n, _ := strconv.ParseUint(digits, 16, 32)
+		if n > unicode.MaxRune || n >= 0xD800 && n <= 0xDFFF {
+			return 0, fmt.Sprintf("invalid escape \\u{%s}: not a Unicode code point", digits)
+		}
+		return rune(n), ""
+	}
+	return 0, fmt.Sprintf("invalid string escape \\%c", c)
+}
+
+// hexDigits reads up to max hex digits.
+func (t *Tokenizer) hexDigits(max int) string {
+	digits := []rune{}
+	for len(digits) < max && strings.ContainsRune("0123456789abcdefABCDEF", t.ch) {
+		digits = append(digits, t.ch)
+		t.next()
+	}
+	return string(digits)
 }
@@ -70,6 +70,30 @@ func TestTokenizer(t *testing.T) {
 			expected: []Token{NAME, ASSIGN, INT, EOF},
 			values:   []string{"x", "", "1", ""},
 		},
+		{
+			input:    "\"a ${x} b\"",
+			expected: []Token{STRSTART, NAME, STREND, EOF},
+			values:   []string{"a ", "x", " b", ""},
+		},
+		{
+			input:    "'${f({k: 1})}-${\"${y}\"}'",
+			expected: []Token{STRSTART, NAME, LPAREN, LBRACE, NAME, COLON, INT, RBRACE, RPAREN, STRMID, STRSTART, NAME, STREND, STREND, EOF},
+			values:   []string{"", "f", "", "", "k", "", "1", "", "", "-", "", "y", "", "", ""},
+		},
+		{
+			input:    "a & b | c ^ ~d << 1 >> 2 ** 3",
+			expected: []Token{NAME, BITAND, NAME, BITOR, NAME, BITXOR, BITNOT, NAME, SHL, INT, SHR, INT, POWER, INT, EOF},
+			values:   []string{"a", "", "b", "", "c", "", "", "d", "", "1", "", "2", "", "3", ""},
+		},
+		{
+			input:    "x &= 1 |= 2 ^= 3 <<= 4 >>= 5 **= 6",
+			expected: []Token{NAME, BITANDEQUAL, INT, BITOREQUAL, INT, BITXOREQUAL, INT, SHLEQUAL, INT, SHREQUAL, INT, POWEREQUAL, INT, EOF},
+			values:   []string{"x", "", "1", "", "2", "", "3", "", "4", "", "5", "", "6", ""},
+		},
+		{
+			input:    "a?.b?.[0]?.() ?? c ??= d ?.5 : 1",
+			expected: []Token{NAME, OPTIONAL, NAME, OPTIONAL, LBRACKET, INT, RBRACKET, OPTIONAL, LPAREN, RPAREN, NULLISH, NAME, NULLISHEQUAL, NAME, QUESTION, FLOAT, COLON, INT, EOF},
+		},
 	}
 
 	for i, test := range tests {
@@ -144,6 +168,19 @@ func TestTokenizerStringLiterals(t *testing.T) {
 		{`"String with \n newline"`, "String with \n newline"},
 		{`"String with \t tab"`, "String with \t tab"},
 		{`"String with \\ backslash"`, "String with \\ backslash"},
+		{`"Cost: \${x} or $5"`, "Cost: ${x} or $5"},
+		{`'Mixed \"quotes\" and \'quotes\''`, `Mixed "quotes" and 'quotes'`},
+		{`"\x41\x7a \u{48}\u{1F600} \0\a\b\f\v\r"`, "Az H\U0001F600 \x00\a\b\f\v\r"},
+		{`r"^\d+\.\d*$"`, `^\d+\.\d*$`},
+		{`r'C:\new\${dir}'`, `C:\new\${dir}`},
+		{`r"C:\" + x`, `C:\`}, // A backslash doesn't escape the quote
+		{`r'say "hi"'`, `say "hi"`},
+		{`r"""both "quotes" and 'quotes'"""`, `both "quotes" and 'quotes'`},
+		{"\"\"\"\n    first\n      second\n\n    third\n    \"\"\"", "first\n  second\n\nthird"},
+		{"'''\r\n\tkeep \"quotes\" and ''\r\n\t'''", "keep \"quotes\" and ''"},
+		{"\"\"\"on the first line\n    indented\n  less\"\"\"", "on the first line\n  indented\nless"},
+		{"r\"\"\"\n  raw \\n ${x}\n  \"\"\"", "raw \\n ${x}"},
+		{`""""""`, ""},
 	}
 
 	for i, test := range tests {
@@ -160,6 +197,39 @@ func TestTokenizerStringLiterals(t *testing.T) {
 	}
 }
 
+func TestTokenizerStringErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		line    int
+		column  int
+		message string
+	}{
+		{`x = "ab\q"`, 1, 8, `invalid string escape \q`},
+		{`x = "\x4g"`, 1, 6, `invalid escape \x4: expected 2 hex digits`},
+		{`x = "\u41"`, 1, 6, `invalid escape \u: expected { after \u`},
+		{`x = "\u{1F6"`, 1, 6, `invalid escape \u{1F6: expected 1 to 6 hex digits and }`},
+		{`x = "\u{110000}"`, 1, 6, `invalid escape \u{110000}: not a Unicode code point`},
+		{`x = "\u{D800}"`, 1, 6, `invalid escape \u{D800}: not a Unicode code point`},
+		{"x = \"\"\"\n  fine\n  bad \\z\n\"\"\"", 3, 7, `invalid string escape \z`},
+		{"x = r'no\nnewline'", 1, 5, "can't have newline in string"},
+		{`x = """unterminated`, 1, 5, "didn't find end quote in string"},
+		{`x = "trailing\`, 1, 5, "didn't find end quote in string"},
+	}
+
+	for i, test := range tests {
+		tokenizer := NewTokenizer([]byte(test.input))
+		tokenizer.Next() // x
+		tokenizer.Next() // =
+		pos, token, value := tokenizer.Next()
+		if token != ILLEGAL || value != test.message {
+			t.Errorf("Test %d: expected ILLEGAL %q, got %s %q", i, test.message, token, value)
+		}
+		if pos.Line != test.line || pos.Column != test.column {
+			t.Errorf("Test %d: expected error at %d:%d, got %d:%d", i, test.line, test.column, pos.Line, pos.Column)
+		}
+	}
+}
+
 func TestTokenizerNumberLiterals(t *testing.T) {
 	tests := []struct {
 		input string
@@ -170,7 +240,21 @@ func TestTokenizerNumberLiterals(t *testing.T) {
 		{"0", INT, "0"},
 		{"3.14", FLOAT, "3.14"},
 		{"0.5", FLOAT, "0.5"},
-		{"-42", MINUS, ""}, // This will be tokenized as MINUS followed by INT
+		{"0xFF", INT, "0xFF"},
+		{"0o755", INT, "0o755"},
+		{"0b1010", INT, "0b1010"},
+		{"1_000_000", INT, "1_000_000"},
+		{"0xdead_BEEF", INT, "0xdead_BEEF"},
+		{"0x_FF", INT, "0x_FF"},
+		{"0b_1010", INT, "0b_1010"},
+		{"6.02e23", FLOAT, "6.02e23"},
+		{"1E-9", FLOAT, "1E-9"},
+		{"2e+3", FLOAT, "2e+3"},
+		{".5", FLOAT, ".5"},
+		{".25e1", FLOAT, ".25e1"},
+		{"3.141_592", FLOAT, "3.141_592"},
+		{"2else", INT, "2"}, // An e without digits isn't an exponent
+		{"-42", MINUS, ""},  // This will be tokenized as MINUS followed by INT
 	}
 
 	for i, test := range tests {
@@ -187,6 +271,37 @@ func TestTokenizerNumberLiterals(t *testing.T) {
 	}
 }
 
+func TestTokenizerNumberErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		column  int
+		message string
+	}{
+		{"0b102", 5, "invalid digit '2' in binary literal"},
+		{"0o78", 4, "invalid digit '8' in octal literal"},
+		{"0xFG", 4, "invalid digit 'G' in hex literal"},
+		{"0x", 1, "expected digits after 0x"},
+		{"1__000", 1, "'_' must separate digits in number"},
+		{"1000_", 1, "'_' must separate digits in number"},
+		{"0x__FF", 1, "'_' must separate digits in number"},
+		{"0x_", 1, "expected digits after 0x"},
+		{"0b_1_", 1, "'_' must separate digits in number"},
+		{"1_.5", 1, "'_' must separate digits in number"},
+		{"1e5_", 1, "'_' must separate digits in number"},
+		{"1.2.3", 1, "unexpected second '.' in number"},
+	}
+
+	for i, test := range tests {
+		pos, token, value := NewTokenizer([]byte(test.input)).Next()
+		if token != ILLEGAL || value != test.message {
+			t.Errorf("Test %d: expected ILLEGAL %q, got %s %q", i, test.message, token, value)
+		}
+		if pos.Column != test.column {
+			t.Errorf("Test %d: expected error at column %d, got %d", i, test.column, pos.Column)
+		}
+	}
+}
+
 // TestUnterminatedMultilineComment tests that unterminated multiline comments produce errors
 func TestUnterminatedMultilineComment(t *testing.T) {
 	input := "x = 5 /* this comment never ends"
@@ -208,3 +323,25 @@ func TestUnterminatedMultilineComment(t *testing.T) {
 		t.Errorf("Expected error message 'unterminated multiline comment', got '%s'", value)
 	}
 }
+
+func TestFileTokenizerPositions(t *testing.T) {
+	tokenizer := NewFileTokenizer("lib.din", []byte("x = 1\n  y"))
+	expected := []Position{
+		{Line: 1, Column: 1, File: "lib.din"},
+		{Line: 1, Column: 3, File: "lib.din"},
+		{Line: 1, Column: 5, File: "lib.din"},
+		{Line: 2, Column: 3, File: "lib.din"},
+	}
+	for _, want := range expected {
+		pos, _, _ := tokenizer.Next()
+		if pos != want {
+			t.Errorf("Expected position %+v, got %+v", want, pos)
+		}
+	}
+	if got := expected[3].String(); got != "lib.din:2:3" {
+		t.Errorf("Expected lib.din:2:3, got %s", got)
+	}
+	if got := (Position{Line: 2, Column: 3}).String(); got != "2:3" {
+		t.Errorf("Expected 2:3, got %s", got)
+	}
+}
@@ -33,6 +33,10 @@ func FormatError(err error, filename string) string {
 		return fmt.Sprintf("%s:%d:%d: Name Error: %s", filename, e.pos.Line, e.pos.Column, e.Message)
 	case RuntimeError:
 		return fmt.Sprintf("%s:%d:%d: Runtime Error: %s", filename, e.pos.Line, e.pos.Column, e.Message)
+	case LimitError:
+		return fmt.Sprintf("%s:%d:%d: Limit Error: %s", filename, e.pos.Line, e.pos.Column, e.Message)
+	case UserError:
+		return fmt.Sprintf("%s:%d:%d: User Error: %s", filename, e.pos.Line, e.pos.Column, e.Message)
 	default:
 		return fmt.Sprintf("%s: %s", filename, err.Error())
 	}
@@ -36,9 +36,9 @@ func GetValueType(value Value) ValueType {
 		return TypeString
 	case []Value:
 		return TypeArray
-	case map[string]Value:
+	case *Object, *errorObject:
 		return TypeObject
-	case *userFunction, builtinFunction:
+	case *userFunction, builtinFunction, *generator:
 		return TypeFunction
 	default:
 		return ValueType(fmt.Sprintf("unknown(%s)", reflect.TypeOf(value).String()))
@@ -99,8 +99,10 @@ func ToString(value Value) string {
 		return v
 	case []Value:
 		return fmt.Sprintf("%v", v)
-	case map[string]Value:
-		return fmt.Sprintf("%v", v)
+	case *Object:
+		return toString(v, false)
+	case *errorObject:
+		return v.text
 	case *userFunction:
 		return fmt.Sprintf("<function %s>", v.Name)
 	case builtinFunction:
@@ -127,8 +129,8 @@ func IsTruthy(value Value) bool {
 		return len(v) > 0
 	case []Value:
 		return len(v) > 0
-	case map[string]Value:
-		return len(v) > 0
+	case *Object:
+		return v.Len() > 0
 	default:
 		return true
 	}
@@ -149,10 +151,10 @@ func DeepCopy(value Value) Value {
 			copy[i] = DeepCopy(item)
 		}
 		return copy
-	case map[string]Value:
-		copy := make(map[string]Value)
-		for key, val := range v {
-			copy[key] = DeepCopy(val)
+	case *Object:
+		copy := NewObject()
+		for _, key := range v.keys {
+			copy.Set(key, DeepCopy(v.values[key]))
 		}
 		return copy
 	default:
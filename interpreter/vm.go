@@ -0,0 +1,618 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// binaryOps and unaryOps hold binaryEvalFuncs and unaryEvalFuncs indexed by
+// operator token, so the VM doesn't look up an operator in a map each time.
+var (
+	binaryOps = operatorTable(binaryEvalFuncs)
+	unaryOps  = operatorTable(unaryEvalFuncs)
+)
+
+// operatorTable converts a map of operator functions to a slice indexed by
+// token.
+func operatorTable[F any](funcs map[Token]F) []F {
+	size := 0
+	for op := range funcs {
+		size = max(size, int(op)+1)
+	}
+	table := make([]F, size)
+	for op, f := range funcs {
+		table[op] = f
+	}
+	return table
+}
+
+// intBinary applies a binary operator to two integers, which is what most
+// arithmetic and comparisons in loops work on. It reports false for the
+// operators it leaves to binaryOps.
+func intBinary(op Token, l, r int) (Value, bool) {
+	switch op {
+	case PLUS:
+		return l + r, true
+	case MINUS:
+		return l - r, true
+	case TIMES:
+		return l * r, true
+	case EQUAL:
+		return l == r, true
+	case NOTEQUAL:
+		return l != r, true
+	case LT:
+		return l < r, true
+	case LTE:
+		return l <= r, true
+	case GT:
+		return l > r, true
+	case GTE:
+		return l >= r, true
+	}
+	return nil, false
+}
+
+// vmFrame is the activation record of a code object running on the VM.
+type vmFrame struct {
+	code *codeObject
+	// pc is the index of the next instruction, saved while a callee runs
+	pc int
+	// base is the operand stack height when the frame was entered
+	base int
+	// env is the caller's environment, restored when the frame returns
+	env *environment
+	// fn is the function being run, or nil for top-level and imported code
+	fn *userFunction
+	// gen is the generator whose body is being run, if any
+	gen *generator
+}
+
+// tryHandler records where to resume when an error is raised inside a try block.
+type tryHandler struct {
+	frame  int          // index of the frame owning the try block
+	target int          // instruction index of the catch block
+	stack  int          // operand stack height to restore
+	env    *environment // environment to restore
+	calls  int          // len(interp.calls) to restore
+}
+
+// vm is a stack-based virtual machine that runs compiled code objects.
+// It shares the interpreter's variables, I/O and statistics, so builtin
+// functions work the same way under both execution engines.
+type vm struct {
+	interp   *interpreter
+	stack    []Value
+	frames   []vmFrame
+	handlers []tryHandler
+}
+
+func newVM(interp *interpreter) *vm {
+	return &vm{
+		interp: interp,
+		stack:  make([]Value, 0, 64),
+		frames: make([]vmFrame, 0, 16),
+	}
+}
+
+// runCode runs top-level code in the global scope and returns its result.
+func (m *vm) runCode(code *codeObject) Value {
+	depth := len(m.frames)
+	m.frames = append(m.frames, vmFrame{code: code, base: len(m.stack), env: m.interp.env})
+	m.interp.env = nil
+	return m.execute(depth)
+}
+
+// call runs a compiled user function from Go code (builtins and the
+// automatic main call) and returns its result.
+func (m *vm) call(f *userFunction, pos Position, args []Value) Value {
+	depth := len(m.frames)
+	m.enter(f, pos, args)
+	return m.execute(depth)
+}
+
+// enter binds the arguments and pushes a new frame for f.
+func (m *vm) enter(f *userFunction, pos Position, args []Value) {
+	interp := m.interp
+	args = f.bindArgs(pos, args)
+	interp.enterCall(f, pos)
+	m.frames = append(m.frames, vmFrame{code: f.code, base: len(m.stack), env: interp.env, fn: f})
+
+	// Set up the local environment, with the arguments in the parameter slots
+	env := newEnvironment(f.scope, f.Closure)
+	copy(env.slots, args)
+	interp.env = env
+	interp.stats.UserCalls++
+}
+
+// execute runs until the frame at index depth returns, resuming at catch
+// blocks whenever an error is caught by a try handler of this invocation.
+func (m *vm) execute(depth int) Value {
+	for {
+		if result, done := m.resume(depth); done {
+			return result
+		}
+	}
+}
+
+func (m *vm) resume(depth int) (result Value, done bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !m.handle(r, depth) {
+				panic(r)
+			}
+		}
+	}()
+	return m.run(depth), true
+}
+
+// handle unwinds to the innermost try handler belonging to frames at or
+// above depth. It returns false if there is none, or if r is a LimitError,
+// which can't be caught.
+func (m *vm) handle(r any, depth int) bool {
+	if _, ok := r.(LimitError); ok || len(m.handlers) == 0 {
+		return false
+	}
+	h := m.handlers[len(m.handlers)-1]
+	if h.frame < depth {
+		return false
+	}
+	m.handlers = m.handlers[:len(m.handlers)-1]
+	m.frames = m.frames[:h.frame+1]
+	m.stack = append(m.stack[:h.stack], Value(newErrorObject(m.interp.attachStack(r))))
+	m.interp.env = h.env
+	m.interp.calls = m.interp.calls[:h.calls]
+	m.frames[h.frame].pc = h.target
+	return true
+}
+
+// loadGlobal returns the value of a global variable.
+func (m *vm) loadGlobal(pos Position, name string) Value {
+	v, ok := m.interp.globals[name]
+	if !ok {
+		panic(nameError(pos, "name %q not found", name))
+	}
+	return v
+}
+
+func (m *vm) push(v Value) {
+	m.stack = append(m.stack, v)
+}
+
+func (m *vm) pop() Value {
+	v := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return v
+}
+
+// run is the VM dispatch loop.
+func (m *vm) run(depth int) Value {
+	interp := m.interp
+	frame := &m.frames[len(m.frames)-1]
+	code := frame.code
+	pc := frame.pc
+
+	for {
+		ins := &code.instructions[pc]
+		pc++
+		interp.stats.Ops += int(ins.ops)
+		if interp.stats.Ops >= interp.limits.nextCheck {
+			interp.checkLimits(ins.pos)
+		}
+
+		switch ins.op {
+		case opNop:
+
+		case opConst:
+			m.push(code.constants[ins.a])
+
+		case opPop:
+			m.stack = m.stack[:len(m.stack)-1]
+
+		case opLoadLocal:
+			v := interp.env.slots[ins.a]
+			if v == (unboundValue{}) {
+				v = m.loadGlobal(ins.pos, interp.env.scope.names[ins.a])
+			}
+			m.push(v)
+
+		case opLoadOuter:
+			env := interp.env
+			for i := int32(0); i < ins.a; i++ {
+				env = env.parent
+			}
+			v := env.slots[ins.b]
+			if v == (unboundValue{}) {
+				v = m.loadGlobal(ins.pos, env.scope.names[ins.b])
+			}
+			m.push(v)
+
+		case opLoadGlobal:
+			m.push(m.loadGlobal(ins.pos, code.names[ins.a]))
+
+		case opStoreLocal:
+			interp.env.slots[ins.a] = m.pop()
+
+		case opStoreOuter:
+			env := interp.env
+			for i := int32(0); i < ins.a; i++ {
+				env = env.parent
+			}
+			env.slots[ins.b] = m.pop()
+
+		case opStoreGlobal:
+			interp.globals[code.names[ins.a]] = m.pop()
+
+		case opDestructure:
+			values := destructure(code.targets[ins.a], m.pop(), nil)
+			for i := len(values) - 1; i >= 0; i-- {
+				m.push(values[i])
+			}
+
+		case opRotate:
+			i := len(m.stack) - 1 - int(ins.a)
+			v := m.stack[i]
+			copy(m.stack[i:], m.stack[i+1:])
+			m.stack[len(m.stack)-1] = v
+
+		case opCompound:
+			current := m.pop()
+			right := m.stack[len(m.stack)-1]
+			m.stack[len(m.stack)-1] = evalCompound(ins.pos, Token(ins.a), current, right)
+
+		case opBinary:
+			r := m.pop()
+			l := m.stack[len(m.stack)-1]
+			if li, ok := l.(int); ok {
+				if ri, ok := r.(int); ok {
+					// Integer arithmetic and comparisons are common enough
+					// to skip the call
+					if v, ok := intBinary(Token(ins.a), li, ri); ok {
+						m.stack[len(m.stack)-1] = v
+						break
+					}
+				}
+			}
+			m.stack[len(m.stack)-1] = binaryOps[ins.a](ins.pos, l, r)
+
+		case opXor:
+			r := m.pop()
+			l := m.stack[len(m.stack)-1]
+			m.stack[len(m.stack)-1] = Value(IsTruthy(l) != IsTruthy(r))
+
+		case opUnary:
+			v := m.stack[len(m.stack)-1]
+			m.stack[len(m.stack)-1] = unaryOps[ins.a](ins.pos, v)
+
+		case opAndJump:
+			l, ok := m.pop().(bool)
+			if !ok {
+				panic(typeError(ins.pos, "and requires two bools"))
+			}
+			if !l {
+				// Short circuit: don't evaluate right if left false
+				m.push(Value(false))
+				pc = int(ins.a)
+			}
+
+		case opOrJump:
+			l, ok := m.pop().(bool)
+			if !ok {
+				panic(typeError(ins.pos, "or requires two bools"))
+			}
+			if l {
+				// Short circuit: don't evaluate right if left true
+				m.push(Value(true))
+				pc = int(ins.a)
+			}
+
+		case opCoalesce:
+			if m.stack[len(m.stack)-1] != nil {
+				pc = int(ins.a)
+			} else {
+				m.pop()
+			}
+
+		case opAssertBool:
+			if _, ok := m.stack[len(m.stack)-1].(bool); !ok {
+				panic(typeError(ins.pos, "%s requires two bools", Token(ins.a)))
+			}
+
+		case opMakeList:
+			n := int(ins.a)
+			values := make([]Value, n)
+			copy(values, m.stack[len(m.stack)-n:])
+			m.stack = m.stack[:len(m.stack)-n]
+			m.push(Value(&values))
+
+		case opMakeMap:
+			n := int(ins.a)
+			items := m.stack[len(m.stack)-2*n:]
+			value := NewObject()
+			for i := 0; i < n; i++ {
+				key := items[2*i]
+				k, ok := key.(string)
+				if !ok {
+					panic(typeError(code.auxPos[int(ins.b)+i], "object key must be string, not %s", typeName(key)))
+				}
+				value.Set(k, items[2*i+1])
+			}
+			m.stack = m.stack[:len(m.stack)-2*n]
+			m.push(Value(value))
+
+		case opListAppend:
+			value := m.pop()
+			list := m.stack[len(m.stack)-1].(*[]Value)
+			*list = append(*list, value)
+
+		case opListExtend:
+			iterable := m.pop()
+			list := m.stack[len(m.stack)-1].(*[]Value)
+			*list = interp.spreadValues(ins.pos, *list, iterable)
+
+		case opMapSet:
+			value := m.pop()
+			key := m.pop()
+			k, ok := key.(string)
+			if !ok {
+				panic(typeError(ins.pos, "object key must be string, not %s", typeName(key)))
+			}
+			m.stack[len(m.stack)-1].(*Object).Set(k, value)
+
+		case opMapMerge:
+			value := m.pop()
+			spreadObject(ins.pos, m.stack[len(m.stack)-1].(*Object), value)
+
+		case opInterpolate:
+			n := int(ins.a)
+			var sb strings.Builder
+			for _, v := range m.stack[len(m.stack)-n:] {
+				sb.WriteString(toString(v, false))
+			}
+			m.stack = m.stack[:len(m.stack)-n]
+			m.push(Value(sb.String()))
+
+		case opMakeSlice:
+			step := m.pop()
+			end := m.pop()
+			m.stack[len(m.stack)-1] = sliceBounds{m.stack[len(m.stack)-1], end, step}
+
+		case opSubscript:
+			subscript := m.pop()
+			container := m.stack[len(m.stack)-1]
+			if ins.a != 0 {
+				m.stack[len(m.stack)-1] = evalOptionalSubscript(ins.pos, container, subscript)
+			} else {
+				m.stack[len(m.stack)-1] = evalSubscript(ins.pos, container, subscript)
+			}
+
+		case opStoreSubscript:
+			value := m.pop()
+			subscript := m.pop()
+			container := m.pop()
+			operator := Token(ins.a)
+			if operator != ASSIGN {
+				valuePos := code.auxPos[ins.b]
+				value = evalCompound(valuePos, operator, evalSubscript(valuePos, container, subscript), value)
+			}
+			interp.assignSubscript(ins.pos, container, subscript, value)
+
+		case opMakeFunction:
+			proto := code.functions[ins.a]
+			m.push(Value(&userFunction{proto.name, proto.parameters, proto.defaults, proto.ellipsis, proto.body, proto.prologue, interp.env, proto.scope, proto.code}))
+
+		case opCheckCallable:
+			if _, ok := m.stack[len(m.stack)-1].(functionType); !ok {
+				panic(typeError(ins.pos, "can't call non-function type %s", typeName(m.stack[len(m.stack)-1])))
+			}
+
+		case opCall, opCallEllipsis, opCallNamed:
+			n := int(ins.a)
+			// Plain calls take their arguments straight from the stack
+			// instead of copying them to a new slice
+			if ins.op == opCall {
+				top := len(m.stack)
+				switch f := m.stack[top-n-1].(type) {
+				case *userFunction:
+					if f.code != nil && !f.scope.generator && !f.Ellipsis && n == len(f.Parameters) {
+						// enter copies the arguments before anything is
+						// pushed over them
+						m.stack = m.stack[:top-n-1]
+						frame.pc = pc
+						m.enter(f, ins.pos, m.stack[top-n:top])
+						frame = &m.frames[len(m.frames)-1]
+						code = frame.code
+						pc = 0
+						continue
+					}
+				case builtinFunction:
+					// Built-ins don't keep their arguments, and nested calls
+					// push above them
+					frame.pc = pc
+					result := f.call(interp, ins.pos, m.stack[top-n:top:top])
+					frame = &m.frames[len(m.frames)-1]
+					m.stack = m.stack[:top-n-1]
+					m.push(result)
+					continue
+				}
+			}
+			args := make([]Value, n, n+1)
+			copy(args, m.stack[len(m.stack)-n:])
+			f := m.stack[len(m.stack)-n-1].(functionType)
+			m.stack = m.stack[:len(m.stack)-n-1]
+			switch ins.op {
+			case opCallEllipsis:
+				iterator := interp.getIterator(code.auxPos[ins.b], args[n-1])
+				args = args[:n-1]
+				for iterator.HasNext() {
+					args = append(args, iterator.Value())
+				}
+			case opCallNamed:
+				call := &code.namedCalls[ins.b]
+				positional := n - len(call.names)
+				named := args[positional:]
+				args = args[:positional:positional]
+				if call.ellipsis {
+					iterator := interp.getIterator(call.ellipsisPos, args[positional-1])
+					args = args[:positional-1]
+					for iterator.HasNext() {
+						args = append(args, iterator.Value())
+					}
+				}
+				args = placeNamedArgs(ins.pos, f, args, call.names, named)
+			}
+			if uf, ok := f.(*userFunction); ok && uf.code != nil && !uf.scope.generator {
+				frame.pc = pc
+				m.enter(uf, ins.pos, args)
+				frame = &m.frames[len(m.frames)-1]
+				code = frame.code
+				pc = 0
+				continue
+			}
+			frame.pc = pc
+			result := interp.callFunction(ins.pos, f, args)
+			// The callee may have run nested code and grown the frame stack
+			frame = &m.frames[len(m.frames)-1]
+			m.push(result)
+
+		case opReturn:
+			result := m.pop()
+			if frame.fn == nil && ins.b == 0 {
+				panic(runtimeError(ins.pos, "can't return at top level"))
+			}
+			index := len(m.frames) - 1
+			for len(m.handlers) > 0 && m.handlers[len(m.handlers)-1].frame >= index {
+				m.handlers = m.handlers[:len(m.handlers)-1]
+			}
+			interp.env = frame.env
+			if frame.fn != nil {
+				interp.exitCall()
+			}
+			if frame.gen != nil {
+				// The body of a generator has finished
+				frame.gen.done = true
+				result = nil
+			}
+			m.stack = m.stack[:frame.base]
+			m.frames = m.frames[:index]
+			if index == depth {
+				return result
+			}
+			frame = &m.frames[len(m.frames)-1]
+			code = frame.code
+			pc = frame.pc
+			m.push(result)
+
+		case opGetIter:
+			if ins.b != 0 {
+				m.stack[len(m.stack)-1] = interp.getEntryIterator(ins.pos, m.stack[len(m.stack)-1])
+			} else {
+				m.stack[len(m.stack)-1] = interp.getIterator(ins.pos, m.stack[len(m.stack)-1])
+			}
+
+		case opForIter:
+			iterator := m.stack[len(m.stack)-1].(iteratorType)
+			if !iterator.HasNext() {
+				pc = int(ins.a)
+			} else if ins.b != 0 {
+				key := iterator.(*entryIterator).Key()
+				m.push(iterator.Value())
+				m.push(key)
+			} else {
+				m.push(iterator.Value())
+			}
+
+		case opJump:
+			pc = int(ins.a)
+
+		case opJumpIfFalse:
+			cond := m.pop()
+			c, ok := cond.(bool)
+			if !ok {
+				switch Token(ins.b) {
+				case WHILE:
+					panic(typeError(ins.pos, "while condition must be bool, got %T", cond))
+				case MATCH:
+					panic(typeError(ins.pos, "match guard must be bool, got %s", typeName(cond)))
+				}
+				panic(typeError(ins.pos, "if condition must be bool, got %s", typeName(cond)))
+			}
+			if !c {
+				pc = int(ins.a)
+			}
+
+		case opJumpIfNotTruthy:
+			if !IsTruthy(m.pop()) {
+				pc = int(ins.a)
+			}
+
+		case opJumpIfBound:
+			if interp.env.slots[ins.b] != (unboundValue{}) {
+				pc = int(ins.a)
+			}
+
+		case opJumpIfNull:
+			if m.stack[len(m.stack)-1] == nil {
+				pc = int(ins.a)
+			}
+
+		case opJumpIfNotNull:
+			if m.pop() != nil {
+				pc = int(ins.a)
+			}
+
+		case opJumpIfSubscript:
+			n := len(m.stack)
+			if evalOptionalSubscript(ins.pos, m.stack[n-2], m.stack[n-1]) != nil {
+				m.stack = m.stack[:n-2]
+				pc = int(ins.a)
+			}
+
+		case opSetupTry:
+			m.handlers = append(m.handlers, tryHandler{len(m.frames) - 1, int(ins.a), len(m.stack), interp.env, len(interp.calls)})
+
+		case opPopTry:
+			m.handlers = m.handlers[:len(m.handlers)-1]
+
+		case opMatch:
+			if !matchPattern(code.patterns[ins.b], m.stack[len(m.stack)-1], interp.env) {
+				pc = int(ins.a)
+			}
+
+		case opEnterScope:
+			interp.env = newEnvironment(code.scopes[ins.a], interp.env)
+
+		case opExitScope:
+			interp.env = interp.env.parent
+
+		case opImport:
+			frame.pc = pc
+			prog := interp.loadImport(ins.pos, code.names[ins.a])
+			m.runCode(compileProgram(code.names[ins.a], prog.Statements))
+			frame = &m.frames[len(m.frames)-1]
+
+		case opThrow:
+			throw(ins.pos, m.pop())
+
+		case opYield:
+			value := m.pop()
+			if frame.gen == nil {
+				panic(runtimeError(ins.pos, "can't yield outside of a generator"))
+			}
+			// Generator frames are always run by resumeGenerator, so this
+			// is the frame execute was called for
+			m.suspend(frame.gen, pc)
+			return value
+
+		case opBreakOutsideLoop:
+			panic(BreakException{ins.pos})
+
+		case opContinueOutside:
+			panic(ContinueException{ins.pos})
+
+		default:
+			// Compiler should never give us this
+			panic(fmt.Sprintf("unknown opcode %s", ins.op))
+		}
+	}
+}
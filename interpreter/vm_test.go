@@ -0,0 +1,638 @@
+package interpreter
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// runWithEngine parses and executes a program on the given engine and
+// returns its output, statistics and execution error.
+func runWithEngine(t *testing.T, program string, engine Engine) (string, *Stats, error) {
+	t.Helper()
+	prog, err := ParseProgram([]byte(program))
+	if err != nil {
+		t.Fatalf("Failed to parse program: %v", err)
+	}
+	var buf bytes.Buffer
+	config := &Config{
+		Stdout: &buf,
+		Engine: engine,
+	}
+	stats, err := Execute(prog, config)
+	return buf.String(), stats, err
+}
+
+// expectOutputOnAllEngines runs a program on the VM and the tree-walker and
+// checks that both print the expected output.
+func expectOutputOnAllEngines(t *testing.T, program, expected string) {
+	t.Helper()
+	for _, engine := range []Engine{EngineVM, EngineTreeWalker} {
+		output, _, err := runWithEngine(t, program, engine)
+		if err != nil {
+			t.Fatalf("Failed to execute program on engine %d: %v", engine, err)
+		}
+		if output != expected {
+			t.Errorf("Expected %q on engine %d, got %q", expected, engine, output)
+		}
+	}
+}
+
+// expectErrorOnAllEngines runs a program on the VM and the tree-walker and
+// checks that both fail with an error containing message.
+func expectErrorOnAllEngines(t *testing.T, program, message string) {
+	t.Helper()
+	for _, engine := range []Engine{EngineVM, EngineTreeWalker} {
+		_, _, err := runWithEngine(t, program, engine)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected error containing %q on engine %d, got %v", message, engine, err)
+		}
+	}
+}
+
+func TestVMMatchesTreeWalker(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+	}{
+		{
+			name: "arithmetic_and_logic",
+			program: `
+			x = 7
+			print(x * 2 + 1, x / 2, x % 3, -x)
+			print(true and false, false or true, true xor true, not false)
+			print(x > 3 ? "big" : "small")
+			`,
+		},
+		{
+			name: "compound_assignment",
+			program: `
+			x = 10
+			x += 5
+			x -= 3
+			x *= 2
+			x /= 4
+			arr = [1, 2, 3]
+			arr[1] += 10
+			obj = {count: 1}
+			obj.count *= 5
+			print(x, arr, obj)
+			`,
+		},
+		{
+			name: "loops_with_break_and_continue",
+			program: `
+			total = 0
+			for (i in range(20)):
+				if (i % 2 == 0) then:
+					continue
+				end
+				if (i > 13) then:
+					break
+				end
+				total += i
+			end
+			j = 0
+			while (true):
+				j += 1
+				if (j == 5) then:
+					break
+				end
+			end
+			print(total, j)
+			`,
+		},
+		{
+			name: "functions_and_recursion",
+			program: `
+			fun fib(n):
+				if (n < 2) then:
+					return n
+				end
+				return fib(n - 1) + fib(n - 2)
+			end
+			fun sum(first, rest...):
+				total = first
+				for (n in rest):
+					total += n
+				end
+				return total
+			end
+			square = fun(n):
+				return n * n
+			end
+			print(fib(15), sum(1, 2, 3, 4), sum([5, 6]...), square(9))
+			`,
+		},
+		{
+			name: "builtins_calling_back",
+			program: `
+			words = ["pear", "fig", "banana"]
+			sort(words, fun(w):
+				return len(w)
+			end)
+			print(words)
+			`,
+		},
+		{
+			name: "try_catch",
+			program: `
+			try:
+				x = 1 / 0
+			catch (e):
+				print("caught", e)
+			end
+			try:
+				try:
+					missing()
+				catch (inner):
+					print("inner", inner)
+					y = [1][3]
+				end
+			catch (outer):
+				print("outer", outer)
+			end
+			`,
+		},
+		{
+			name: "match_statement",
+			program: `
+			fun shape(v):
+				match (v):
+					case [x, ...rest] if x > 0:
+						return "positive head " + str(len(rest))
+					case {kind: "point", x, y}:
+						return "point " + str(x + y)
+					case string s:
+						return "string " + s
+					default:
+						return "other"
+				end
+			end
+			for (v in [[1, 2], [-1], {kind: "point", x: 1, y: 2}, "s", 3]):
+				match (shape(v)):
+					case "other":
+						continue
+					case r:
+						print(r)
+				end
+			end
+			`,
+		},
+		{
+			name: "destructuring",
+			program: `
+			[a, b, ...rest] = [1, 2, 3, 4]
+			[a, b] = [b, a]
+			{name, age: years} = {name: "Ann", age: 30}
+			grid = [[0, 0], [0, 0]]
+			[grid[0][1], grid[1][0]] = [a, b]
+			for ([i, {v}] in [[0, {v: "x"}], [1, {v: "y"}]]):
+				print(i, v)
+			end
+			fun f([p, q], {r}):
+				return p + q + r
+			end
+			print(a, b, rest, name, years, grid, f([1, 2], {r: 3}))
+			`,
+		},
+		{
+			name: "two_variable_for",
+			program: `
+			total = 0
+			for (i, x in [10, 20, 30]):
+				total += i * x
+			end
+			for (k, v in {a: 1}):
+				print(k, v)
+			end
+			for (i, ch in "ab"):
+				print(i, ch)
+			end
+			print(total)
+			`,
+		},
+		{
+			name: "generators",
+			program: `
+			fun evens(limit):
+				n = 0
+				while (n < limit):
+					try:
+						yield n
+					catch (e):
+						throw e
+					finally:
+						n += 2
+					end
+				end
+			end
+			fun pairs(it):
+				for (i, v in it):
+					match (v):
+						case x if x > 2:
+							yield [i, x]
+					end
+				end
+			end
+			for ([i, x] in pairs(evens(9))):
+				print(i, x)
+			end
+			g = evens(3)
+			print(g.next(), g.next(), g.next(), max(evens(7)...))
+			`,
+		},
+		{
+			name: "interpolation",
+			program: `
+			fun label(n): return "#${n}" end
+			for (i in range(3)):
+				print("${label(i)}: ${i * i} of ${[i, {n: "${i}"}]}")
+			end
+			`,
+		},
+		{
+			name: "numeric_literals",
+			program: `
+			print(0xFF + 0o17 + 0b_11, 1_000 * 2.5e-1, .5 + 1e2)
+			match (16):
+				case 0x10: print("sixteen")
+			end
+			`,
+		},
+		{
+			name: "bitwise_and_power",
+			program: `
+			x = 0xFF
+			x &= ~0x0F
+			x >>= 2
+			y = [1]
+			y[0] **= 2 ** 3
+			print(x, y, 1 | 2 ^ 7 & 3 << 1, -2 ** 2, 2 ** -1)
+			`,
+		},
+		{
+			name: "defaults_and_named_arguments",
+			program: `
+			fun greet(name, greeting = "Hello " + name, [a, b] = [1, 2]):
+				return [greeting, a + b]
+			end
+			print(greet("Ana"), greet("Bo", "Yo", [3, 4]), greet(greeting: "Hi", name: "Cy"))
+			`,
+		},
+		{
+			name: "spread_in_literals",
+			program: `
+			base = {a: 1, b: 2}
+			xs = [3, 4]
+			print([0, ...xs, 5, ..."hi"], {...base, b: 3, ...{c: xs}})
+			`,
+		},
+		{
+			name: "slicing",
+			program: `
+			xs = [0, 1, 2, 3, 4, 5]
+			xs[1:3] = xs[::-2]
+			xs[-1] *= 2
+			print(xs, xs[:-2], "slice"[1::2])
+			`,
+		},
+		{
+			name: "optional_chaining",
+			program: `
+			user = {profile: null, tags: ["a"], greet: fun(n): return "hi " + n end}
+			print(user?.profile?.name, user.tags?.[0], user?.greet?.("x"), user.profile?.(1)[2])
+			print(user.profile ?? "none", 0 ?? 1)
+			user.profile ??= {name: "ann"}
+			user.profile ??= {name: "bob"}
+			print(user.profile?.name)
+			`,
+		},
+		{
+			name: "comprehensions",
+			program: `
+			xs = [1, 2, 3, 4]
+			print([x * x for x in xs if x % 2 == 0], {k: v for (k, v in {a: 1, b: null}) if v != null})
+			print([[i, j] for i in range(3) for j in range(i)], [[y for y in range(x)] for x in xs])
+			`,
+		},
+		{
+			name: "main_is_called",
+			program: `
+			fun helper():
+				return "helped"
+			end
+			fun main():
+				print("main", helper())
+			end
+			`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vmOutput, vmStats, vmErr := runWithEngine(t, test.program, EngineVM)
+			twOutput, twStats, twErr := runWithEngine(t, test.program, EngineTreeWalker)
+			if vmErr != nil || twErr != nil {
+				t.Fatalf("Unexpected errors: vm=%v, tree-walker=%v", vmErr, twErr)
+			}
+			if vmOutput != twOutput {
+				t.Errorf("Output differs:\nvm:\n%s\ntree-walker:\n%s", vmOutput, twOutput)
+			}
+			if *vmStats != *twStats {
+				t.Errorf("Stats differ: vm=%+v, tree-walker=%+v", *vmStats, *twStats)
+			}
+		})
+	}
+}
+
+func TestControlFlowThroughTry(t *testing.T) {
+	program := `
+	fun find_first_even(xs):
+		for (x in xs):
+			try:
+				if (x % 2 == 0) then:
+					return x
+				end
+			catch (e):
+				print("unexpected", e)
+			end
+		end
+		return null
+	end
+
+	seen = []
+	for (i in range(10)):
+		try:
+			if (i == 2) then:
+				continue
+			end
+			if (i == 5) then:
+				break
+			end
+			append(seen, i)
+		catch (e):
+			print("unexpected", e)
+		end
+	end
+
+	fun fails():
+		return [][0]
+	end
+	try:
+		fails()
+	catch (e):
+		print("recovered")
+	end
+	print(find_first_even([1, 3, 4, 5]), seen)
+	`
+
+	expectOutputOnAllEngines(t, program, "recovered\n4 [0, 1, 3, 4]\n")
+}
+
+func TestTryFinally(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+	}{
+		{
+			name: "normal_and_caught",
+			program: `
+			try:
+				print("try")
+			catch (e):
+				print("catch")
+			finally:
+				print("finally")
+			end
+			try:
+				x = [][0]
+			catch (e):
+				print("catch", e.type)
+			finally:
+				print("finally")
+			end`,
+			expected: "try\nfinally\ncatch ValueError\nfinally\n",
+		},
+		{
+			name: "return_from_try_and_catch",
+			program: `
+			log = []
+			fun from_try():
+				try:
+					return "try"
+				catch (e):
+					return "catch"
+				finally:
+					append(log, "cleanup 1")
+				end
+			end
+			fun from_catch():
+				try:
+					throw "boom"
+				catch (e):
+					return "catch"
+				finally:
+					append(log, "cleanup 2")
+				end
+			end
+			fun overridden():
+				try:
+					return "try"
+				catch (e):
+				finally:
+					return "finally"
+				end
+			end
+			print(from_try(), from_catch(), overridden(), log)`,
+			expected: "try catch finally [\"cleanup 1\", \"cleanup 2\"]\n",
+		},
+		{
+			name: "break_and_continue",
+			program: `
+			for (i in range(5)):
+				try:
+					if (i == 1) then:
+						continue
+					end
+					if (i == 3) then:
+						break
+					end
+				catch (e):
+				finally:
+					print("finally", i)
+				end
+			end`,
+			expected: "finally 0\nfinally 1\nfinally 2\nfinally 3\n",
+		},
+		{
+			name: "nested_finally_blocks",
+			program: `
+			fun f():
+				try:
+					try:
+						return 1
+					catch (e):
+					finally:
+						print("inner")
+					end
+				catch (e):
+				finally:
+					print("outer")
+				end
+			end
+			print(f())`,
+			expected: "inner\nouter\n1\n",
+		},
+		{
+			name: "uncaught_error_in_catch",
+			program: `
+			try:
+				try:
+					x = 1 / 0
+				catch (e):
+					throw "from catch"
+				finally:
+					print("finally")
+				end
+			catch (e):
+				print(e.message)
+			end`,
+			expected: "finally\nfrom catch\n",
+		},
+		{
+			name: "brace_syntax",
+			program: `
+			fun f() {
+				try {
+					return 1
+				} catch (e) {
+					return 2
+				} finally {
+					print("finally")
+				}
+			}
+			print(f())`,
+			expected: "finally\n1\n",
+		},
+		{
+			name: "finally_without_catch",
+			program: `
+			fun risky():
+				try:
+					print("try")
+					x = [][0]
+					print("unreachable")
+				finally:
+					print("finally")
+				end
+				print("after")
+			end
+			try:
+				risky()
+			catch (e):
+				print("caught", e.type)
+			end
+			fun early():
+				try:
+					return "try"
+				finally:
+					print("cleanup")
+				end
+			end
+			print(early())`,
+			expected: "try\nfinally\ncaught ValueError\ncleanup\ntry\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectOutputOnAllEngines(t, test.program, test.expected)
+		})
+	}
+}
+
+func TestVMErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		message string
+	}{
+		{"name_error", `print(nope)`, `name error at 1:7: name "nope" not found`},
+		{"type_error", `x = 1 + "a"`, "type error at 1:7"},
+		{"call_non_function", `x = 5
+x()`, "can't call non-function type integer"},
+		{"if_condition", `if (1) then: print(1) end`, "if condition must be bool, got integer"},
+		{"top_level_return", `return 1`, "can't return at top level"},
+		{"finally_without_catch", "try:\nx = 1 / 0\nfinally:\nprint(1)\nend", "value error at 2:7: can't divide by zero"},
+		{"break_outside_loop", `break`, "break at 1:1"},
+		{"break_in_function", `fun f(): break end
+for (i in range(3)): f() end`, "break at 1:10"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectErrorOnAllEngines(t, test.program, test.message)
+		})
+	}
+}
+
+func TestCompilerDisassembly(t *testing.T) {
+	prog, err := ParseProgram([]byte(`
+	fun add(a, b):
+		return a + b
+	end
+	print(add(1, 2))
+	`))
+	if err != nil {
+		t.Fatalf("Failed to parse program: %v", err)
+	}
+
+	code := compileProgram("<program>", prog.Statements)
+	listing := code.String()
+	for _, expected := range []string{"MAKE_FUNCTION", "STORE_GLOBAL", "LOAD_LOCAL", "CALL", "== add ==", "BINARY", "RETURN"} {
+		if !strings.Contains(listing, expected) {
+			t.Errorf("Expected disassembly to contain %q, got:\n%s", expected, listing)
+		}
+	}
+}
+
+func TestEvaluateOnVM(t *testing.T) {
+	expr, err := ParseExpression([]byte(`[1, 2, 3][1] * 10`))
+	if err != nil {
+		t.Fatalf("Failed to parse expression: %v", err)
+	}
+	value, _, err := Evaluate(expr, &Config{})
+	if err != nil {
+		t.Fatalf("Failed to evaluate expression: %v", err)
+	}
+	if value != 20 {
+		t.Errorf("Expected 20, got %v", value)
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	for _, engine := range []Engine{EngineVM, EngineTreeWalker} {
+		expr, err := ParseExpression([]byte(`[1, 2][5]`))
+		if err != nil {
+			t.Fatalf("Failed to parse expression: %v", err)
+		}
+		_, _, err = Evaluate(expr, &Config{Engine: engine})
+		var valueErr ValueError
+		if !errors.As(err, &valueErr) || !strings.Contains(err.Error(), "subscript 5 out of range") {
+			t.Errorf("Expected ValueError on engine %d, got %v", engine, err)
+		}
+
+		expr, err = ParseExpression([]byte(`[x * 2 for x in range(1000)]`))
+		if err != nil {
+			t.Fatalf("Failed to parse expression: %v", err)
+		}
+		_, _, err = Evaluate(expr, &Config{Engine: engine, MaxOps: 100})
+		var limitErr LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("Expected LimitError on engine %d, got %v", engine, err)
+		}
+	}
+}
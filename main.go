@@ -11,9 +11,10 @@ import (
 
 // CLI represents the command line interface
 type CLI struct {
-	args    []string
-	profile bool
-	analyze bool
+	args       []string
+	profile    bool
+	analyze    bool
+	treeWalker bool
 }
 
 // NewCLI creates a new CLI instance
@@ -39,6 +40,10 @@ func (c *CLI) Run() error {
 			c.analyze = true
 			// Remove the flag from args
 			c.args = append(c.args[:i], c.args[i+1:]...)
+		} else if arg == "--tree-walker" || arg == "-t" {
+			c.treeWalker = true
+			// Remove the flag from args
+			c.args = append(c.args[:i], c.args[i+1:]...)
 		}
 	}
 
@@ -76,6 +81,7 @@ func (c *CLI) printUsage() {
 	fmt.Println("Flags:")
 	fmt.Println("  --profile, -p              - Enable performance profiling output")
 	fmt.Println("  --analyze, -a              - Analyze syntax only (no execution)")
+	fmt.Println("  --tree-walker, -t          - Run on the AST tree-walker instead of the bytecode VM")
 }
 
 func (c *CLI) printVersion() {
@@ -133,6 +139,10 @@ func (c *CLI) runScript(filename string) error {
 	// Create options based on profile flag
 	options := &interpreter.RunProgramOptions{
 		ShowProfiling: c.profile,
+		Filename:      filename,
+	}
+	if c.treeWalker {
+		options.Engine = interpreter.EngineTreeWalker
 	}
 
 	// Execute the program with options